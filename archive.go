@@ -0,0 +1,75 @@
+package logrotate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// backupDir returns the directory of the backups, ArchiveDir or the
+// directory of Filename.
+func (l *Logrotate) backupDir() string {
+	dir := filepath.Dir(l.Filename)
+	if l.ArchiveDir == "" {
+		return dir
+	}
+	if filepath.IsAbs(l.ArchiveDir) {
+		return l.ArchiveDir
+	}
+	return filepath.Join(dir, l.ArchiveDir)
+}
+
+// backupBase returns the path which backup names extend, the base name of
+// Filename in backupDir.
+func (l *Logrotate) backupBase() string {
+	if l.ArchiveDir == "" {
+		return l.Filename
+	}
+	return filepath.Join(l.backupDir(), filepath.Base(l.Filename))
+}
+
+// makeBackupDir creates a missing ArchiveDir.
+func (l *Logrotate) makeBackupDir() error {
+	if l.ArchiveDir == "" {
+		return nil
+	}
+
+	return l.makeDir(l.backupDir())
+}
+
+// moveFile moves src to dst with MoveFunc, or renames it and copies and
+// removes src if they are on different file systems.
+func (l *Logrotate) moveFile(src, dst string) error {
+	if l.MoveFunc != nil {
+		err := l.MoveFunc(src, dst)
+		if err != nil {
+			return fmt.Errorf("logrotate: move %q: %w", src, err)
+		}
+		return nil
+	}
+
+	return l.relocate(src, dst)
+}
+
+// relocate renames src to dst, or copies and removes src if they are on
+// different file systems.
+func (l *Logrotate) relocate(src, dst string) error {
+	err := l.retry(func() error { return l.filesystem().Rename(src, dst) })
+	if err == nil {
+		return nil
+	}
+	if !crossDevice(err) {
+		return fmt.Errorf("logrotate: rename %q: %w", src, err)
+	}
+
+	err = l.copyFile(src, dst)
+	if err != nil {
+		return err
+	}
+
+	err = l.filesystem().Remove(src)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("logrotate: remove %q: %w", src, err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package logrotate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveDir(t *testing.T) {
+	filename := testFilename(t)
+	dir := filepath.Dir(filename)
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithMaxBackups(1), WithClock(newTestClock()))
+	l.ArchiveDir = "archive"
+	defer closeLog(t, l)
+
+	write(t, l, "123456789\n")
+	write(t, l, "abc\n")
+	if names := dirNames(t, dir); len(names) != 2 {
+		t.Fatalf("files = %q, want app.log and archive", names)
+	}
+	archive := filepath.Join(dir, "archive")
+	if got := backupContents(t, archive); len(got) != 1 || got[0] != "123456789\n" {
+		t.Fatalf("archived backups = %q", got)
+	}
+
+	// retention scans the archive.
+	write(t, l, "def456789\n")
+	if got := backupContents(t, archive); len(got) != 1 || got[0] != "abc\n" {
+		t.Fatalf("archived backups = %q, want the newest", got)
+	}
+}
+
+func TestMoveFunc(t *testing.T) {
+	filename := testFilename(t)
+	var moves [][2]string
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(newTestClock()))
+	l.MoveFunc = func(src, dst string) error {
+		moves = append(moves, [2]string{src, dst})
+		return os.Rename(src, dst)
+	}
+	defer closeLog(t, l)
+
+	write(t, l, "123456789\n")
+	write(t, l, "abc\n")
+	if len(moves) != 1 || moves[0][0] != filename {
+		t.Fatalf("moves = %q, want one of %s", moves, filename)
+	}
+	if got := readFile(t, moves[0][1]); got != "123456789\n" {
+		t.Fatalf("backup = %q", got)
+	}
+	if got := readFile(t, filename); got != "abc\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
+
+func TestMoveFuncError(t *testing.T) {
+	filename := testFilename(t)
+	errUpload := errors.New("upload failed")
+	fail := true
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(newTestClock()))
+	l.MoveFunc = func(src, dst string) error {
+		if fail {
+			fail = false
+			return errUpload
+		}
+		return os.Rename(src, dst)
+	}
+	defer closeLog(t, l)
+
+	write(t, l, "123456789\n")
+	if _, err := l.Write([]byte("abc\n")); !errors.Is(err, errUpload) {
+		t.Fatalf("Write = %v, want the MoveFunc error", err)
+	}
+	write(t, l, "def\n")
+	if got := backupContents(t, filepath.Dir(filename)); len(got) != 1 || got[0] != "123456789\n" {
+		t.Fatalf("backups = %q", got)
+	}
+	if got := readFile(t, filename); got != "def\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
@@ -0,0 +1,36 @@
+//go:build !windows && !plan9
+
+package logrotate
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// exdevFS is the os fileSystem failing renames as across file systems.
+type exdevFS struct {
+	osFS
+}
+
+func (exdevFS) Rename(oldpath, newpath string) error {
+	return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+}
+
+func TestArchiveDirCrossDevice(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(newTestClock()))
+	l.ArchiveDir = "archive"
+	l.fs = exdevFS{}
+	defer closeLog(t, l)
+
+	write(t, l, "123456789\n")
+	write(t, l, "abc\n")
+	if got := backupContents(t, filepath.Join(filepath.Dir(filename), "archive")); len(got) != 1 || got[0] != "123456789\n" {
+		t.Fatalf("archived backups = %q, want the copied file", got)
+	}
+	if got := readFile(t, filename); got != "abc\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
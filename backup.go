@@ -0,0 +1,491 @@
+package logrotate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BackupMode selects how backup files are named.
+type BackupMode int
+
+const (
+	// TimestampMode names backups by the time of rotation, like
+	// "app.log.2006-01-02T15-04-05".
+	TimestampMode BackupMode = iota
+
+	// IndexMode names backups "app.log.1", "app.log.2" and so on, where
+	// the usual backup is moved to ".1" and the others are shifted up.
+	IndexMode
+)
+
+// backup is a rotated log file found next to Filename. The path is always
+// the uncompressed name, compressed marks that path with the compressed
+// extension exists.
+type backup struct {
+	path       string
+	time       time.Time
+	seq        int // of backups within the same second, or the index.
+	size       int64
+	plain      bool // the uncompressed file exists.
+	compressed bool
+}
+
+// BackupInfo describes a backup file.
+type BackupInfo struct {
+	Path       string    // of the file, compressed or not.
+	Time       time.Time // of rotation, or modification in IndexMode.
+	Size       int64     // on disk.
+	Compressed bool
+}
+
+// Backups returns the backup files of l from newest to oldest. Files in the
+// backup directory which are not backups of Filename are ignored.
+func (l *Logrotate) Backups() ([]BackupInfo, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	list, err := l.backups()
+	if err != nil {
+		return nil, err
+	}
+
+	ext := l.compressExt()
+	infos := make([]BackupInfo, len(list))
+	for i, b := range list {
+		path := b.path
+		if !b.plain {
+			path += ext
+		}
+		infos[i] = BackupInfo{Path: path, Time: b.time, Size: b.size, Compressed: !b.plain}
+	}
+
+	return infos, nil
+}
+
+// backups returns rotated files of l sorted from newest to oldest. Files
+// whose suffix is not a backup timestamp, or index in IndexMode, are
+// ignored. Backups in IndexMode are timed by modification time, and so are
+// the files starting with the base name of Filename with BackupNameFunc.
+func (l *Logrotate) backups() ([]backup, error) {
+	// a backup being compressed exists in both forms, count it once.
+	seen := make(map[string]int)
+
+	var list []backup
+	for i, dir := range l.backupDirs() {
+		files, err := l.scanDir(dir)
+		if os.IsNotExist(err) && (i > 0 || l.ArchiveDir != "") {
+			// nothing was archived yet, or the directory was removed.
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("logrotate: read dir %q: %w", dir, err)
+		}
+
+		for _, f := range files {
+			path := filepath.Join(dir, f.name)
+			if j, ok := seen[path]; ok {
+				// prefer the plain file, it is complete while compressing.
+				if !f.compressed {
+					list[j].size = f.size
+					list[j].plain = true
+				}
+				list[j].compressed = true
+				continue
+			}
+
+			seen[path] = len(list)
+			list = append(list, backup{
+				path:       path,
+				time:       f.time,
+				seq:        f.seq,
+				size:       f.size,
+				plain:      !f.compressed,
+				compressed: f.compressed,
+			})
+		}
+	}
+
+	// ties are ordered by path, so retention picks the same files in
+	// every run.
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if l.BackupMode == IndexMode {
+			if a.seq != b.seq {
+				return a.seq < b.seq
+			}
+			return a.path < b.path
+		}
+		if !a.time.Equal(b.time) {
+			return a.time.After(b.time)
+		}
+		if a.seq != b.seq {
+			return a.seq > b.seq
+		}
+		return a.path < b.path
+	})
+
+	return list, nil
+}
+
+// baseName returns the base name of Filename.
+func (l *Logrotate) baseName() string {
+	return filepath.Base(l.Filename)
+}
+
+// matchParts returns the parts of backup names before and after the
+// timestamp, or index, like nameParts. With BackupNameFunc any name starting
+// with the base name of Filename matches.
+func (l *Logrotate) matchParts() (prefix, suffix string) {
+	if l.BackupNameFunc != nil {
+		return l.baseName(), ""
+	}
+	return l.nameParts()
+}
+
+// ParseBackupTime returns the rotation time in the name of a backup of
+// filename, like "app.log.2006-01-02T15-04-05.gz", in the default format
+// and the local time zone. Directories are ignored, only the base names
+// have to match.
+func ParseBackupTime(name, filename string) (time.Time, error) {
+	l := Logrotate{Filename: filename}
+	return l.ParseBackupTime(name)
+}
+
+// ParseBackupTime is like the function ParseBackupTime for the backups of
+// l, using its BackupTimeFormat, UTC and compressed extension.
+func (l *Logrotate) ParseBackupTime(name string) (time.Time, error) {
+	prefix, suffix := l.nameParts()
+	s := strings.TrimSuffix(filepath.Base(name), l.compressExt())
+	if !strings.HasPrefix(s, prefix) || !strings.HasSuffix(s, suffix) || len(s) < len(prefix)+len(suffix) {
+		return time.Time{}, fmt.Errorf("logrotate: %q is not a backup of %q", name, l.Filename)
+	}
+
+	t, _, err := l.parseSuffix(s[len(prefix) : len(s)-len(suffix)])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("logrotate: %q is not a backup of %q: %w", name, l.Filename, err)
+	}
+	return t, nil
+}
+
+// nameParts returns the base name parts of timestamped backups before and
+// after the timestamp, "app.log." and "", or "app." and ".log" with
+// InfixTimestamp. The suffix starts with the InstanceID.
+func (l *Logrotate) nameParts() (prefix, suffix string) {
+	base := filepath.Base(l.Filename)
+	prefix = base + "."
+	if l.BackupMode != TimestampMode {
+		return prefix, ""
+	}
+
+	if l.InfixTimestamp {
+		if ext := filepath.Ext(base); ext != "" && ext != base {
+			prefix, suffix = strings.TrimSuffix(base, ext)+".", ext
+		}
+	}
+	if l.InstanceID != "" {
+		suffix = "." + l.InstanceID + suffix
+	}
+	return prefix, suffix
+}
+
+// backupDirs returns the directories searched for backups, backupDir and
+// the directories PostRotate moved backups to.
+func (l *Logrotate) backupDirs() []string {
+	dir := l.backupDir()
+	dirs := []string{dir}
+	l.cacheMu.Lock()
+	for d := range l.postDirs {
+		if d != dir {
+			dirs = append(dirs, d)
+		}
+	}
+	l.cacheMu.Unlock()
+	sort.Strings(dirs[1:])
+	return dirs
+}
+
+// parseSuffix parses the timestamp, optional ".N" sequence and optional
+// EpochFunc token of a backup name with the parts of nameParts removed.
+func (l *Logrotate) parseSuffix(s string) (time.Time, int, error) {
+	t, seq, err := l.parseStamp(s)
+	if err != nil {
+		// the backup of a file with an EpochFunc token.
+		if i := strings.LastIndexByte(s, epochSep); i >= 0 {
+			return l.parseStamp(s[:i])
+		}
+	}
+	return t, seq, err
+}
+
+// parseStamp parses a backup timestamp with an optional sequence number.
+func (l *Logrotate) parseStamp(s string) (time.Time, int, error) {
+	t, err := parseTime(l.timeFormat(), s, l.location())
+	if err == nil {
+		return t, 0, nil
+	}
+
+	i := strings.LastIndexByte(s, '.')
+	if i < 0 {
+		return time.Time{}, 0, err
+	}
+
+	seq, serr := strconv.Atoi(s[i+1:])
+	if serr != nil || seq <= 0 {
+		return time.Time{}, 0, err
+	}
+
+	t, err = parseTime(l.timeFormat(), s[:i], l.location())
+	return t, seq, err
+}
+
+// parseTime parses s in loc and rejects values which are not formatted
+// exactly by layout, such as extra fractional seconds.
+func parseTime(layout, s string, loc *time.Location) (time.Time, error) {
+	t, err := time.ParseInLocation(layout, s, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if t.Format(layout) != s {
+		return time.Time{}, fmt.Errorf("logrotate: backup time %q does not match layout %q", s, layout)
+	}
+
+	return t, nil
+}
+
+// ApplyRetention removes the backups beyond the retention settings now,
+// including backups of earlier runs. Retention is otherwise applied on the
+// first open of Filename and after each rotation.
+func (l *Logrotate) ApplyRetention() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.removeBackups()
+}
+
+// startRetention applies the retention on the first open, in background
+// with AsyncCleanup.
+func (l *Logrotate) startRetention() error {
+	if !l.retains() {
+		return nil
+	}
+
+	if l.AsyncCleanup {
+		l.queueMill("")
+		return nil
+	}
+	return l.retentionError(l.removeBackups())
+}
+
+// retains reports whether any retention limit is set.
+func (l *Logrotate) retains() bool {
+	return l.MaxBackups > 0 || l.MaxAge > 0 || l.MaxTotalSize > 0
+}
+
+// removeBackups removes the backups selected by pruneBackups, except those
+// still queued for the mill.
+func (l *Logrotate) removeBackups() error {
+	remove, over, err := l.pruneBackups()
+	if err != nil {
+		return err
+	}
+
+	for _, b := range remove {
+		if l.isQueued(b.path) {
+			// the mill applies the retention again once done with it.
+			continue
+		}
+
+		err := l.removeBackup(b.path)
+		if err != nil {
+			return err
+		}
+	}
+
+	if over != nil {
+		return fmt.Errorf("%w: %q", ErrOverTotalSize, over.path)
+	}
+	return nil
+}
+
+// retentionError reports err to ErrorHandler instead of returning it if it
+// is ErrOverTotalSize, which must not fail a Write.
+func (l *Logrotate) retentionError(err error) error {
+	if errors.Is(err, ErrOverTotalSize) {
+		l.reportError(err)
+		return nil
+	}
+	return err
+}
+
+// removeBackup removes the backup path in both forms and its sidecars.
+func (l *Logrotate) removeBackup(path string) error {
+	l.diagf("remove %q", path)
+	ext := l.compressExt()
+	for _, name := range []string{path, path + ext, path + hashSuffix, path + ext + hashSuffix} {
+		err := l.removeFile(name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneBackups selects the backups to remove from the list of backups()
+// ordered from newest to oldest, in three steps:
+//
+//  1. the backups after the first MaxBackups, unless FailOnExceed,
+//  2. of the rest, the backups older than MaxAge,
+//  3. of the rest, the backups after the first ones whose sizes add up to
+//     MaxTotalSize, but at least one is kept. The first kept backup taking
+//     the total past MaxTotalSize is returned as over.
+//
+// The KeepMinimum newest backups are never selected by any step, and the
+// first two steps select by position and time alone, so their order does
+// not matter.
+func (l *Logrotate) pruneBackups() (remove []backup, over *backup, err error) {
+	if !l.retains() {
+		return nil, nil, nil
+	}
+
+	list, err := l.backups()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var keep []backup
+	if n := l.backupLimit(); n > 0 && l.RetentionPolicy != FailOnExceed && len(list) > n {
+		remove = list[n:]
+		list = list[:n]
+	}
+
+	cutoff := l.timeNow().Add(-l.MaxAge)
+	for i, b := range list {
+		if l.MaxAge > 0 && i >= l.KeepMinimum && b.time.Before(cutoff) {
+			remove = append(remove, b)
+			continue
+		}
+		keep = append(keep, b)
+	}
+
+	if l.MaxTotalSize > 0 {
+		least := l.KeepMinimum
+		if least < 1 {
+			least = 1
+		}
+
+		var total int64
+		for i, b := range keep {
+			total += b.size
+			if total <= l.MaxTotalSize {
+				continue
+			}
+
+			if i < least {
+				if over == nil {
+					over = &keep[i]
+				}
+				continue
+			}
+			remove = append(remove, keep[i:]...)
+			break
+		}
+	}
+
+	return remove, over, nil
+}
+
+// backupLimit returns the number of backups MaxBackups keeps, at least
+// KeepMinimum, or zero if it keeps all.
+func (l *Logrotate) backupLimit() int {
+	if l.MaxBackups <= 0 || l.MaxBackups >= l.KeepMinimum {
+		return l.MaxBackups
+	}
+	return l.KeepMinimum
+}
+
+// PruneCandidates returns the backup files the retention settings would
+// remove now, without removing them.
+func (l *Logrotate) PruneCandidates() ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	remove, _, err := l.pruneBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	ext := l.compressExt()
+	var paths []string
+	for _, b := range remove {
+		if b.plain {
+			paths = append(paths, b.path)
+		}
+		if b.compressed {
+			paths = append(paths, b.path+ext)
+		}
+	}
+
+	return paths, nil
+}
+
+// shiftBackups renames every indexed backup to the next index, so that ".1"
+// is free for the current file. Backups shifted past MaxBackups are removed.
+func (l *Logrotate) shiftBackups() error {
+	list, err := l.backups()
+	if err != nil {
+		return err
+	}
+
+	ext := l.compressExt()
+	for i := len(list) - 1; i >= 0; i-- {
+		b := list[i]
+		next := b.seq + 1
+
+		if n := l.backupLimit(); n > 0 && next > n {
+			if err := l.removeBackup(b.path); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// backups moved by PostRotate are shifted where they are.
+		dst := filepath.Join(filepath.Dir(b.path), filepath.Base(l.indexName(next)))
+		for _, e := range []string{"", ext, hashSuffix, ext + hashSuffix} {
+			if err := l.renameFile(b.path+e, dst+e); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// indexName returns the name of the backup with index i in IndexMode.
+func (l *Logrotate) indexName(i int) string {
+	return l.backupBase() + "." + strconv.Itoa(i)
+}
+
+// renameFile renames src to dst, a missing src is not an error.
+func (l *Logrotate) renameFile(src, dst string) error {
+	err := l.filesystem().Rename(src, dst)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("logrotate: rename %q: %w", src, err)
+	}
+	return nil
+}
+
+// removeFile removes the named file, a missing file is not an error.
+func (l *Logrotate) removeFile(name string) error {
+	err := l.filesystem().Remove(name)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("logrotate: remove %q: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,801 @@
+package logrotate
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeBackup creates the backup of filename rotated at t with content s and
+// returns its base name.
+func writeBackup(t testing.TB, filename string, at time.Time, s string) string {
+	t.Helper()
+	name := filename + "." + at.Format(backupTimeFormat)
+	if err := os.WriteFile(name, []byte(s), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Base(name)
+}
+
+func TestMaxBackupsKeepsNewest(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithMaxBackups(3), WithClock(clock))
+	defer closeLog(t, l)
+
+	var want []string
+	for i := 0; i < 6; i++ {
+		write(t, l, "123456789\n")
+		if i > 0 {
+			want = append(want, filepath.Base(filename)+"."+clock.Now().Format(backupTimeFormat))
+		}
+		clock.Advance(time.Minute)
+	}
+
+	got := backupFiles(t, filepath.Dir(filename))
+	if !reflect.DeepEqual(got, want[2:]) {
+		t.Fatalf("backups = %q, want %q", got, want[2:])
+	}
+}
+
+func TestMaxBackupsRemovesExisting(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	var names []string
+	for i := 5; i > 0; i-- {
+		names = append(names, writeBackup(t, filename, clock.Now().Add(-time.Duration(i)*time.Hour), "old"))
+	}
+
+	l := newLogrotate(filename, WithMaxBackups(3), WithClock(clock))
+	write(t, l, "x")
+	closeLog(t, l)
+
+	got := backupFiles(t, filepath.Dir(filename))
+	if !reflect.DeepEqual(got, names[2:]) {
+		t.Fatalf("backups = %q, want %q", got, names[2:])
+	}
+}
+
+func TestMaxBackupsZeroKeepsAll(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(clock))
+	defer closeLog(t, l)
+
+	for i := 0; i < 6; i++ {
+		write(t, l, "123456789\n")
+		clock.Advance(time.Minute)
+	}
+
+	if n := len(backupFiles(t, filepath.Dir(filename))); n != 5 {
+		t.Fatalf("got %d backups, want 5", n)
+	}
+}
+
+func TestMaxBackupsIgnoresOtherFiles(t *testing.T) {
+	filename := testFilename(t)
+	dir := filepath.Dir(filename)
+	for _, name := range []string{"app.log.txt", "app.log.old", "other.log.2020-01-01T00-00-00"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithMaxBackups(1), WithClock(newTestClock()))
+	write(t, l, "123456789\n")
+	write(t, l, "123456789\n")
+	closeLog(t, l)
+
+	if n := len(dirNames(t, dir)); n != 5 {
+		t.Fatalf("files = %q, want the 3 foreign files, a backup and app.log", dirNames(t, dir))
+	}
+}
+
+func TestMaxAgeByNameTime(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	now := clock.Now()
+	old := writeBackup(t, filename, now.Add(-31*24*time.Hour), "old")
+	recent := writeBackup(t, filename, now.Add(-29*24*time.Hour), "recent")
+	future := writeBackup(t, filename, now.Add(24*time.Hour), "future")
+
+	// a fresh modification time must not save the old backup.
+	if err := os.Chtimes(filepath.Join(filepath.Dir(filename), old), now, now); err != nil {
+		t.Fatal(err)
+	}
+
+	l := newLogrotate(filename, WithMaxAge(30*24*time.Hour), WithClock(clock))
+	write(t, l, "x")
+	closeLog(t, l)
+
+	got := backupFiles(t, filepath.Dir(filename))
+	want := []string{recent, future}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("backups = %q, want %q", got, want)
+	}
+}
+
+func TestMaxAgeAfterRotation(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithMaxAge(time.Hour), WithClock(clock))
+	defer closeLog(t, l)
+
+	write(t, l, "123456789\n")
+	write(t, l, "123456789\n")
+	if n := len(backupFiles(t, filepath.Dir(filename))); n != 1 {
+		t.Fatalf("got %d backups, want 1", n)
+	}
+
+	clock.Advance(2 * time.Hour)
+	write(t, l, "123456789\n")
+	got := backupFiles(t, filepath.Dir(filename))
+	want := []string{filepath.Base(filename) + "." + clock.Now().Format(backupTimeFormat)}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("backups = %q, want %q", got, want)
+	}
+}
+
+func TestMaxAgeZeroKeepsAll(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	writeBackup(t, filename, clock.Now().AddDate(-10, 0, 0), "old")
+
+	l := newLogrotate(filename, WithClock(clock))
+	write(t, l, "x")
+	closeLog(t, l)
+
+	if n := len(backupFiles(t, filepath.Dir(filename))); n != 1 {
+		t.Fatalf("got %d backups, want 1", n)
+	}
+}
+
+func TestBackupNamePortable(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithClock(newTestClock()))
+
+	name := l.backupName()
+	base := filepath.Base(name)
+	if strings.ContainsAny(base, `:\/`) {
+		t.Fatalf("backup name %q has a separator or colon", base)
+	}
+	if filepath.Join(filepath.Dir(filename), base) != name {
+		t.Fatalf("backup name %q is not in the directory of %q", name, filename)
+	}
+	if got, err := l.ParseBackupTime(base); err != nil || !got.Equal(l.timeNow()) {
+		t.Fatalf("ParseBackupTime(%q) = %v, %v", base, got, err)
+	}
+}
+
+func TestBackupTimeFormat(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	l := newLogrotate(filename, WithClock(clock), WithMaxBackups(1))
+	l.BackupTimeFormat = "20060102-150405"
+	defer closeLog(t, l)
+
+	write(t, l, "one\n")
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	write(t, l, "two\n")
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := backupFiles(t, filepath.Dir(filename))
+	want := []string{"app.log." + clock.Now().Format("20060102-150405")}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("backups = %q, want %q", got, want)
+	}
+}
+
+func TestBackupTimeFormatSeparator(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename)
+	l.BackupTimeFormat = "2006/01/02"
+	defer closeLog(t, l)
+
+	write(t, l, "x\n")
+	if err := l.Rotate(); err == nil || !strings.Contains(err.Error(), "path separator") {
+		t.Fatalf("Rotate = %v, want a path separator error", err)
+	}
+	if got := backupFiles(t, filepath.Dir(filename)); len(got) != 0 {
+		t.Fatalf("backups = %q, want none", got)
+	}
+}
+
+func TestBackupNamesWithinOneSecond(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	l := newLogrotate(filename, WithClock(clock))
+	defer closeLog(t, l)
+
+	for _, s := range []string{"one\n", "two\n", "three\n"} {
+		write(t, l, s)
+		if err := l.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stamp := "app.log." + clock.Now().Format(backupTimeFormat)
+	want := []string{stamp, stamp + ".1", stamp + ".2"}
+	if got := backupFiles(t, filepath.Dir(filename)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("backups = %q, want %q", got, want)
+	}
+	for i, s := range []string{"one\n", "two\n", "three\n"} {
+		if got := readFile(t, filepath.Join(filepath.Dir(filename), want[i])); got != s {
+			t.Errorf("%s = %q, want %q", want[i], got, s)
+		}
+	}
+
+	// the retention orders them by sequence as well.
+	list, err := l.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, b := range list {
+		if got := filepath.Base(b.path); got != want[len(want)-1-i] {
+			t.Errorf("backup %d = %q, want %q", i, got, want[len(want)-1-i])
+		}
+	}
+}
+
+func TestMaxTotalSize(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	now := clock.Now()
+	var names []string
+	for i, size := range []int{50, 10, 30, 20, 40} {
+		at := now.Add(time.Duration(i-5) * time.Hour)
+		names = append(names, writeBackup(t, filename, at, strings.Repeat("x", size)))
+	}
+
+	// newest first the sizes add up to 40, 60, 90: the 30 byte backup and
+	// the older ones go.
+	l := newLogrotate(filename, WithClock(clock))
+	l.MaxTotalSize = 80
+	write(t, l, strings.Repeat("y", 100)) // the active file is not counted.
+	closeLog(t, l)
+
+	got := backupFiles(t, filepath.Dir(filename))
+	if want := names[3:]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("backups = %q, want %q", got, want)
+	}
+}
+
+func TestMaxTotalSizeOversized(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	old := writeBackup(t, filename, clock.Now().Add(-time.Hour), "old\n")
+
+	var errs []error
+	l := newLogrotate(filename, WithClock(clock))
+	l.MaxTotalSize = 10
+	l.ErrorHandler = func(err error) { errs = append(errs, err) }
+	write(t, l, strings.Repeat("y", 100)+"\n")
+	if err := l.Rotate(); err != nil {
+		t.Fatalf("Rotate = %v, want the error only reported", err)
+	}
+	closeLog(t, l)
+
+	// the newest backup alone is over the limit, the history before goes.
+	got := backupFiles(t, filepath.Dir(filename))
+	if len(got) != 1 || got[0] == old {
+		t.Fatalf("backups = %q, want only the newest", got)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrOverTotalSize) || !strings.Contains(errs[0].Error(), got[0]) {
+		t.Fatalf("reported %v, want ErrOverTotalSize for %s", errs, got[0])
+	}
+}
+
+func TestMaxTotalSizeWithMaxBackups(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	now := clock.Now()
+	var names []string
+	for i, size := range []int{10, 10, 10, 10, 10} {
+		at := now.Add(time.Duration(i-5) * time.Hour)
+		names = append(names, writeBackup(t, filename, at, strings.Repeat("x", size)))
+	}
+
+	tests := []struct {
+		maxBackups int
+		maxTotal   int64
+		want       []string
+	}{
+		{maxBackups: 2, maxTotal: 100, want: names[3:]},
+		{maxBackups: 4, maxTotal: 25, want: names[3:]},
+		{maxBackups: 4, maxTotal: 35, want: names[2:]},
+	}
+	for _, tt := range tests {
+		l := newLogrotate(filename, WithClock(clock), WithMaxBackups(tt.maxBackups))
+		l.MaxTotalSize = tt.maxTotal
+		remove, _, err := l.pruneBackups()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var kept []string
+		for _, name := range names {
+			removed := false
+			for _, b := range remove {
+				removed = removed || filepath.Base(b.path) == name
+			}
+			if !removed {
+				kept = append(kept, name)
+			}
+		}
+		if !reflect.DeepEqual(kept, tt.want) {
+			t.Errorf("MaxBackups %d, MaxTotalSize %d: kept %q, want %q", tt.maxBackups, tt.maxTotal, kept, tt.want)
+		}
+	}
+}
+
+func TestBackupNameUTC(t *testing.T) {
+	zone := time.FixedZone("UTC+5", 5*3600)
+	clock := &testClock{t: time.Date(2024, 3, 1, 2, 30, 0, 0, zone)}
+
+	local := newLogrotate("app.log", WithClock(clock))
+	if got, want := local.backupName(), "app.log."+clock.Now().In(time.Local).Format(backupTimeFormat); got != want {
+		t.Errorf("local backupName() = %q, want %q", got, want)
+	}
+
+	utc := newLogrotate("app.log", WithClock(clock), WithUTC(true))
+	if got, want := utc.backupName(), "app.log.2024-02-29T21-30-00"; got != want {
+		t.Errorf("UTC backupName() = %q, want %q", got, want)
+	}
+
+	// the retention reads the names back in UTC.
+	stamp, err := utc.ParseBackupTime("app.log.2024-02-29T21-30-00")
+	if err != nil || !stamp.Equal(clock.Now()) {
+		t.Errorf("ParseBackupTime = %v, %v, want %v", stamp, err, clock.Now())
+	}
+}
+
+func TestIndexModeCascade(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithClock(newTestClock()))
+	l.BackupMode = IndexMode
+	defer closeLog(t, l)
+
+	for _, s := range []string{"one\n", "two\n", "three\n"} {
+		write(t, l, s)
+		if err := l.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := filepath.Dir(filename)
+	want := map[string]string{"app.log.1": "three\n", "app.log.2": "two\n", "app.log.3": "one\n"}
+	if got := backupFiles(t, dir); len(got) != len(want) {
+		t.Fatalf("backups = %q", got)
+	}
+	for name, content := range want {
+		if got := readFile(t, filepath.Join(dir, name)); got != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+	}
+}
+
+func TestIndexModeMaxBackups(t *testing.T) {
+	for _, tt := range []struct {
+		keepMinimum int
+		want        []string
+	}{
+		{0, []string{"app.log.1", "app.log.2"}},
+		{3, []string{"app.log.1", "app.log.2", "app.log.3"}},
+	} {
+		filename := testFilename(t)
+		l := newLogrotate(filename, WithClock(newTestClock()), WithMaxBackups(2), WithKeepMinimum(tt.keepMinimum))
+		l.BackupMode = IndexMode
+
+		for _, s := range []string{"one\n", "two\n", "three\n", "four\n"} {
+			write(t, l, s)
+			if err := l.Rotate(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		closeLog(t, l)
+
+		if got := backupFiles(t, filepath.Dir(filename)); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("KeepMinimum %d: backups = %q, want %q", tt.keepMinimum, got, tt.want)
+		}
+		if got := readFile(t, filepath.Join(filepath.Dir(filename), "app.log.2")); got != "three\n" {
+			t.Fatalf("KeepMinimum %d: app.log.2 = %q, want the third file", tt.keepMinimum, got)
+		}
+	}
+}
+
+func TestBackups(t *testing.T) {
+	filename := testFilename(t)
+	dir := filepath.Dir(filename)
+	at := newTestClock().Now()
+	plain := writeBackup(t, filename, at, "plain\n")
+
+	gz := writeBackup(t, filename, at.Add(time.Hour), "") + ".gz"
+	f, err := os.Create(filepath.Join(dir, gz))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(f)
+	zw.Write([]byte("compressed\n"))
+	zw.Close()
+	f.Close()
+	os.Remove(filepath.Join(dir, strings.TrimSuffix(gz, ".gz")))
+
+	for _, name := range []string{"other.log." + at.Format(backupTimeFormat), "app.log.notatime", "app.log.2024-13-45T99-00-00"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l := newLogrotate(filename)
+	list, err := l.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("Backups() = %+v, want 2", list)
+	}
+	if list[0].Path != filepath.Join(dir, gz) || !list[0].Compressed || !list[0].Time.Equal(at.Add(time.Hour)) {
+		t.Errorf("newest = %+v, want the compressed %s", list[0], gz)
+	}
+	if list[1].Path != filepath.Join(dir, plain) || list[1].Compressed || list[1].Size != 6 || !list[1].Time.Equal(at) {
+		t.Errorf("oldest = %+v, want the plain %s", list[1], plain)
+	}
+}
+
+func TestPruneCandidates(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want []int // hours ago of the removed backups.
+	}{
+		{"none", nil, nil},
+		{"max backups", []Option{WithMaxBackups(2)}, []int{3, 4}},
+		{"max age", []Option{WithMaxAge(150 * time.Minute)}, []int{3, 4}},
+		{"both", []Option{WithMaxBackups(3), WithMaxAge(90 * time.Minute)}, []int{2, 3, 4}},
+	}
+	for _, tt := range tests {
+		filename := testFilename(t)
+		dir := filepath.Dir(filename)
+		clock := newTestClock()
+		names := make(map[int]string)
+		for h := 1; h <= 4; h++ {
+			names[h] = filepath.Join(dir, writeBackup(t, filename, clock.Now().Add(-time.Duration(h)*time.Hour), "x"))
+		}
+
+		l := newLogrotate(filename, append(tt.opts, WithClock(clock))...)
+		got, err := l.PruneCandidates()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []string
+		for _, h := range tt.want {
+			want = append(want, names[h])
+		}
+		sort.Strings(got)
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: PruneCandidates() = %q, want %q", tt.name, got, want)
+		}
+		if n := len(backupFiles(t, dir)); n != 4 {
+			t.Errorf("%s: %d backups left, want all 4", tt.name, n)
+		}
+	}
+}
+
+func TestBackupNameFunc(t *testing.T) {
+	filename := testFilename(t)
+	dir := filepath.Dir(filename)
+	clock := newTestClock()
+	n := 0
+	l := newLogrotate(filename, WithMaxBackups(2), WithClock(clock))
+	l.BackupNameFunc = func(name string, at time.Time) string {
+		n++
+		return fmt.Sprintf("%s-host1-%d", name, n)
+	}
+	defer closeLog(t, l)
+
+	for i := 1; i <= 3; i++ {
+		write(t, l, fmt.Sprintf("%d\n", i))
+		if err := l.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		// retention goes by modification time.
+		at := clock.Now().Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(fmt.Sprintf("%s-host1-%d", filename, i), at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(t, l, "4\n")
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"app.log-host1-3", "app.log-host1-4"}
+	if got := backupFiles(t, dir); !reflect.DeepEqual(got, want) {
+		t.Fatalf("backups = %q, want %q", got, want)
+	}
+}
+
+func TestKeepMinimum(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	var names []string
+	for d := 10; d >= 7; d-- {
+		names = append(names, writeBackup(t, filename, clock.Now().Add(-time.Duration(d)*24*time.Hour), "old\n"))
+	}
+
+	l := newLogrotate(filename, WithMaxAge(24*time.Hour), WithKeepMinimum(2), WithClock(clock))
+	write(t, l, "new\n")
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	closeLog(t, l)
+
+	// the two newest stay, the rotated one and the newest old one.
+	got := backupFiles(t, filepath.Dir(filename))
+	want := append(names[3:], filepath.Base(filename)+"."+clock.Now().Format(backupTimeFormat))
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("backups = %q, want %q", got, want)
+	}
+}
+
+// TestPruneBackups lists the backups removed by combinations of the
+// retention limits, of five backups of 10 bytes, b1 the newest one hour
+// old to b5 five hours old.
+func TestPruneBackups(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	b := map[string]string{}
+	for h := 1; h <= 5; h++ {
+		b[fmt.Sprintf("b%d", h)] = writeBackup(t, filename, clock.Now().Add(-time.Duration(h)*time.Hour), strings.Repeat("x", 10))
+	}
+
+	tests := []struct {
+		maxBackups  int
+		maxAge      time.Duration
+		maxTotal    int64
+		keepMinimum int
+		remove      []string
+		over        string
+	}{
+		{maxBackups: 3, remove: []string{"b4", "b5"}},
+		{maxAge: 150 * time.Minute, remove: []string{"b3", "b4", "b5"}},
+		{maxTotal: 25, remove: []string{"b3", "b4", "b5"}},
+		{maxTotal: 5, remove: []string{"b2", "b3", "b4", "b5"}, over: "b1"},
+		{maxBackups: 4, maxAge: 150 * time.Minute, remove: []string{"b5", "b3", "b4"}},
+		{maxBackups: 2, maxTotal: 35, remove: []string{"b3", "b4", "b5"}},
+		{maxAge: 270 * time.Minute, maxTotal: 25, remove: []string{"b5", "b3", "b4"}},
+		{maxBackups: 4, maxAge: 210 * time.Minute, maxTotal: 15, remove: []string{"b5", "b4", "b2", "b3"}},
+		{maxBackups: 1, keepMinimum: 3, remove: []string{"b4", "b5"}},
+		{maxAge: 30 * time.Minute, keepMinimum: 3, remove: []string{"b4", "b5"}},
+		{maxTotal: 15, keepMinimum: 3, remove: []string{"b4", "b5"}, over: "b2"},
+		{maxBackups: 1, maxAge: 30 * time.Minute, maxTotal: 5, keepMinimum: 2, remove: []string{"b3", "b4", "b5"}, over: "b1"},
+		{maxBackups: 2, keepMinimum: 6},
+	}
+	for _, tt := range tests {
+		l := newLogrotate(filename, WithClock(clock), WithMaxBackups(tt.maxBackups), WithMaxAge(tt.maxAge), WithKeepMinimum(tt.keepMinimum))
+		l.MaxTotalSize = tt.maxTotal
+		remove, over, err := l.pruneBackups()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, r := range remove {
+			got = append(got, filepath.Base(r.path))
+		}
+		var want []string
+		for _, name := range tt.remove {
+			want = append(want, b[name])
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%+v: removed %q, want %q", tt, got, want)
+		}
+
+		var gotOver string
+		if over != nil {
+			gotOver = filepath.Base(over.path)
+		}
+		if gotOver != b[tt.over] {
+			t.Errorf("%+v: over = %q, want %q", tt, gotOver, b[tt.over])
+		}
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	var names []string
+	for h := 5; h >= 1; h-- {
+		names = append(names, writeBackup(t, filename, clock.Now().Add(-time.Duration(h)*time.Hour), "old\n"))
+	}
+
+	l := newLogrotate(filename, WithMaxBackups(2), WithClock(clock))
+	defer closeLog(t, l)
+	if err := l.ApplyRetention(); err != nil {
+		t.Fatal(err)
+	}
+	if got := backupFiles(t, filepath.Dir(filename)); !reflect.DeepEqual(got, names[3:]) {
+		t.Fatalf("backups = %q, want %q", got, names[3:])
+	}
+}
+
+func TestRetentionOnFirstOpen(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	var names []string
+	for h := 5; h >= 1; h-- {
+		names = append(names, writeBackup(t, filename, clock.Now().Add(-time.Duration(h)*time.Hour), "old\n"))
+	}
+
+	l := newLogrotate(filename, WithMaxBackups(2), WithClock(clock))
+	write(t, l, "new\n")
+	closeLog(t, l)
+	if got := backupFiles(t, filepath.Dir(filename)); !reflect.DeepEqual(got, names[3:]) {
+		t.Fatalf("backups = %q, want %q", got, names[3:])
+	}
+}
+
+func TestParseBackupTime(t *testing.T) {
+	want := time.Date(2024, 3, 1, 10, 4, 5, 0, time.Local)
+	for _, name := range []string{
+		"app.log.2024-03-01T10-04-05",
+		"app.log.2024-03-01T10-04-05.gz",
+		"/var/log/app.log.2024-03-01T10-04-05",
+	} {
+		got, err := ParseBackupTime(name, "/var/log/app.log")
+		if err != nil || !got.Equal(want) {
+			t.Errorf("ParseBackupTime(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+
+	for _, name := range []string{
+		"other.log.2024-03-01T10-04-05",
+		"app.log.2024-03-01",
+		"app.log",
+		"app.log.gz",
+	} {
+		if _, err := ParseBackupTime(name, "app.log"); err == nil {
+			t.Errorf("ParseBackupTime(%q) succeeded", name)
+		}
+	}
+
+	l := newLogrotate("app.log")
+	l.BackupTimeFormat = "20060102-1504"
+	l.UTC = true
+	got, err := l.ParseBackupTime("app.log.20240301-1004")
+	if want := time.Date(2024, 3, 1, 10, 4, 0, 0, time.UTC); err != nil || !got.Equal(want) {
+		t.Errorf("custom format: ParseBackupTime = %v, %v, want %v", got, err, want)
+	}
+}
+
+func TestInfixTimestamp(t *testing.T) {
+	for _, tt := range []struct {
+		infix bool
+		want  []string
+	}{
+		{false, []string{"app.log.2024-03-01T10-02-00", "app.log.2024-03-01T10-03-00"}},
+		{true, []string{"app.2024-03-01T10-02-00.log", "app.2024-03-01T10-03-00.log"}},
+	} {
+		filename := testFilename(t)
+		clock := newTestClock()
+		l := newLogrotate(filename, WithMaxBackups(2), WithClock(clock))
+		l.InfixTimestamp = tt.infix
+		for i := 0; i < 4; i++ {
+			write(t, l, "x\n")
+			if err := l.Rotate(); err != nil {
+				t.Fatal(err)
+			}
+			clock.Advance(time.Minute)
+		}
+		closeLog(t, l)
+
+		// retention found the older ones in the same naming.
+		if got := backupFiles(t, filepath.Dir(filename)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("infix %t: backups = %q, want %q", tt.infix, got, tt.want)
+		}
+	}
+}
+
+func TestInfixTimestampCompress(t *testing.T) {
+	for _, tt := range []struct {
+		infix, compress bool
+		want            string
+	}{
+		{false, false, "app.log.2024-03-01T10-00-00"},
+		{false, true, "app.log.2024-03-01T10-00-00.gz"},
+		{true, false, "app.2024-03-01T10-00-00.log"},
+		{true, true, "app.2024-03-01T10-00-00.log.gz"},
+	} {
+		filename := testFilename(t)
+		l := newLogrotate(filename, WithCompress(tt.compress), WithClock(newTestClock()))
+		l.InfixTimestamp = tt.infix
+		write(t, l, "x\n")
+		if err := l.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		closeLog(t, l)
+
+		if got := backupFiles(t, filepath.Dir(filename)); !reflect.DeepEqual(got, []string{tt.want}) {
+			t.Errorf("infix %t, compress %t: backups = %q, want %q", tt.infix, tt.compress, got, tt.want)
+		}
+	}
+}
+
+func TestPostRotate(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		filename := testFilename(t)
+		dir := filepath.Dir(filename)
+		clock := newTestClock()
+		l := newLogrotate(filename, WithMaxBackups(2), WithClock(clock))
+		l.AsyncCleanup = async
+		l.PostRotate = func(name string) (string, error) {
+			sub := filepath.Join(dir, "2024", "03")
+			if err := os.MkdirAll(sub, 0755); err != nil {
+				return "", err
+			}
+			path := filepath.Join(sub, filepath.Base(name))
+			return path, os.Rename(name, path)
+		}
+
+		var want []string
+		for i := 0; i < 4; i++ {
+			write(t, l, fmt.Sprintf("%d\n", i))
+			if err := l.Rotate(); err != nil {
+				t.Fatal(err)
+			}
+			want = append(want, "app.log."+clock.Now().Format(backupTimeFormat))
+			clock.Advance(time.Minute)
+		}
+		closeLog(t, l)
+
+		if got := backupFiles(t, dir); len(got) != 1 {
+			t.Fatalf("async %t: files beside app.log = %q, want only the subdirectory", async, got)
+		}
+		if got := dirNames(t, filepath.Join(dir, "2024", "03")); !reflect.DeepEqual(got, want[2:]) {
+			t.Fatalf("async %t: moved backups = %q, want %q", async, got, want[2:])
+		}
+	}
+}
+
+func TestInstanceID(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	var ls []*Logrotate
+	for _, id := range []string{"host1", "host2"} {
+		l := newLogrotate(filename, WithMaxBackups(1), WithClock(clock))
+		l.InstanceID = id
+		ls = append(ls, l)
+	}
+
+	// host1 rotates three times, host2 once.
+	for i := 0; i < 3; i++ {
+		if err := ls[0].Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Minute)
+	}
+	if err := ls[1].Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range ls {
+		closeLog(t, l)
+	}
+
+	want := []string{"app.log.2024-03-01T10-02-00.host1", "app.log.2024-03-01T10-03-00.host2"}
+	if got := backupFiles(t, filepath.Dir(filename)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("backups = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,158 @@
+package logrotate
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// racyWindow is how old the modification time of a directory must be to
+// trust that an unchanged time means unchanged entries. Files added within
+// the resolution of the time stamps would go unnoticed otherwise.
+const racyWindow = 2 * time.Second
+
+// dirFile is a backup file found in a directory.
+type dirFile struct {
+	name       string // base name, without the compressed extension.
+	compressed bool
+	time       time.Time
+	seq        int
+	size       int64
+}
+
+// dirCache is the last scan of a backup directory.
+type dirCache struct {
+	key   string    // of the naming the files were matched with.
+	mtime time.Time // of the directory, zero if it must be read again.
+	files []dirFile
+}
+
+// scanDir returns the backup files in dir. The files are cached until the
+// modification time of dir changes, and a new scan only stats the files it
+// did not see before. A file still being compressed is looked at again by
+// every scan, and so is every file in IndexMode, where shifting reuses
+// names.
+func (l *Logrotate) scanDir(dir string) ([]dirFile, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	l.cacheMu.Lock()
+	defer l.cacheMu.Unlock()
+
+	key := l.scanKey()
+	old := l.dirCaches[dir]
+	if old != nil && old.key != key {
+		old = nil
+	}
+	if old != nil && !old.mtime.IsZero() && old.mtime.Equal(info.ModTime()) {
+		return old.files, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]dirFile)
+	if old != nil && l.BackupMode != IndexMode {
+		for _, f := range old.files {
+			known[l.dirFileName(f)] = f
+		}
+	}
+
+	base := l.baseName()
+	prefix, suffix := l.matchParts()
+	ext := l.compressExt()
+	plain := make(map[string]bool)
+
+	var files []dirFile
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || name == base || !strings.HasPrefix(name, prefix) || strings.HasSuffix(name, tempSuffix) || strings.HasSuffix(name, hashSuffix) {
+			continue
+		}
+
+		if f, ok := known[name]; ok {
+			files = append(files, f)
+			if !f.compressed {
+				plain[f.name] = true
+			}
+			continue
+		}
+
+		compressed := strings.HasSuffix(name, ext)
+		name = strings.TrimSuffix(name, ext)
+		if name == base || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) || len(name) < len(prefix)+len(suffix) {
+			continue
+		}
+		stamp := name[len(prefix) : len(name)-len(suffix)]
+
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		f := dirFile{name: name, compressed: compressed, size: fi.Size()}
+		if l.BackupNameFunc != nil {
+			f.time = fi.ModTime()
+		} else if l.BackupMode == IndexMode {
+			f.seq, err = strconv.Atoi(stamp)
+			if err != nil || f.seq <= 0 {
+				continue
+			}
+			f.time = fi.ModTime()
+		} else {
+			f.time, f.seq, err = l.parseSuffix(stamp)
+			if err != nil {
+				continue
+			}
+		}
+
+		files = append(files, f)
+		if !compressed {
+			plain[name] = true
+		}
+	}
+
+	c := &dirCache{key: key, mtime: info.ModTime(), files: files}
+	if time.Since(info.ModTime()) < racyWindow {
+		c.mtime = time.Time{}
+	}
+
+	// a compressed file next to its plain one may still grow, drop it so
+	// the next scan stats it again.
+	cached := files[:0:0]
+	for _, f := range files {
+		if f.compressed && plain[f.name] {
+			c.mtime = time.Time{}
+			continue
+		}
+		cached = append(cached, f)
+	}
+	c.files = cached
+
+	if l.dirCaches == nil {
+		l.dirCaches = make(map[string]*dirCache)
+	}
+	l.dirCaches[dir] = c
+
+	return files, nil
+}
+
+// dirFileName returns the name of f in its directory.
+func (l *Logrotate) dirFileName(f dirFile) string {
+	if f.compressed {
+		return f.name + l.compressExt()
+	}
+	return f.name
+}
+
+// scanKey returns the settings which decide what scanDir finds.
+func (l *Logrotate) scanKey() string {
+	prefix, suffix := l.matchParts()
+	return fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%d\x00%t\x00%t", prefix, suffix, l.compressExt(), l.timeFormat(), l.BackupMode, l.UTC, l.BackupNameFunc != nil)
+}
@@ -0,0 +1,138 @@
+package logrotate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setDirTime sets the modification time of dir, which is cached when it is
+// older than racyWindow.
+func setDirTime(t testing.TB, dir string, at time.Time) {
+	t.Helper()
+	if err := os.Chtimes(dir, at, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScanDirCache(t *testing.T) {
+	filename := testFilename(t)
+	dir := filepath.Dir(filename)
+	clock := newTestClock()
+	l := newLogrotate(filename, WithClock(clock))
+	writeBackup(t, filename, clock.Now().Add(-2*time.Hour), "old\n")
+	old := time.Now().Add(-time.Hour)
+	setDirTime(t, dir, old)
+
+	scan := func() int {
+		t.Helper()
+		files, err := l.scanDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(files)
+	}
+	if n := scan(); n != 1 {
+		t.Fatalf("scan found %d backups, want 1", n)
+	}
+
+	// the listing is cached while the directory time stays the same.
+	writeBackup(t, filename, clock.Now().Add(-time.Hour), "external\n")
+	setDirTime(t, dir, old)
+	if n := scan(); n != 1 {
+		t.Fatalf("scan found %d backups, want the cached 1", n)
+	}
+
+	// a changed directory time is read again.
+	setDirTime(t, dir, old.Add(time.Minute))
+	if n := scan(); n != 2 {
+		t.Fatalf("scan found %d backups, want 2 after the change", n)
+	}
+
+	// a file appearing now is seen by the next scan.
+	writeBackup(t, filename, clock.Now().Add(-time.Minute), "new\n")
+	if n := scan(); n != 3 {
+		t.Fatalf("scan found %d backups, want 3 after a new file", n)
+	}
+	if got := backupFiles(t, dir); len(got) != 3 {
+		t.Fatalf("backups = %q", got)
+	}
+}
+
+func BenchmarkScanDir(b *testing.B) {
+	filename := filepath.Join(b.TempDir(), "app.log")
+	dir := filepath.Dir(filename)
+	at := newTestClock().Now()
+	for i := 0; i < 2000; i++ {
+		writeBackup(b, filename, at.Add(-time.Duration(i)*time.Minute), "x\n")
+	}
+	setDirTime(b, dir, time.Now().Add(-time.Hour))
+
+	for _, cached := range []bool{false, true} {
+		b.Run(fmt.Sprintf("cached=%t", cached), func(b *testing.B) {
+			l := newLogrotate(filename)
+			for i := 0; i < b.N; i++ {
+				if !cached {
+					l.dirCaches = nil
+				}
+				if _, err := l.scanDir(dir); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestScanDirCacheRetention(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	l := newLogrotate(filename, WithMaxBackups(2), WithClock(clock))
+	defer closeLog(t, l)
+
+	write(t, l, "one\n")
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// another process adds a backup after the scan of the rotation.
+	clock.Advance(time.Hour)
+	writeBackup(t, filename, clock.Now().Add(-time.Minute), "external\n")
+	clock.Advance(time.Hour)
+	write(t, l, "two\n")
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := backupContents(t, filepath.Dir(filename)); len(got) != 2 || got[0] != "external\n" || got[1] != "two\n" {
+		t.Fatalf("backups = %q, want the 2 newest with the external one", got)
+	}
+}
+
+func BenchmarkRotateManyBackups(b *testing.B) {
+	for _, cached := range []bool{false, true} {
+		b.Run(fmt.Sprintf("cached=%t", cached), func(b *testing.B) {
+			filename := filepath.Join(b.TempDir(), "app.log")
+			at := newTestClock().Now()
+			for i := 0; i < 2000; i++ {
+				writeBackup(b, filename, at.Add(-time.Duration(i+1)*time.Minute), "x\n")
+			}
+			l := newLogrotate(filename, WithMaxBackups(1<<20))
+			defer l.Close()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if !cached {
+					l.dirCaches = nil
+				}
+				if _, err := l.Write(benchmarkData[:64]); err != nil {
+					b.Fatal(err)
+				}
+				if err := l.Rotate(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
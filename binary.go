@@ -0,0 +1,31 @@
+package logrotate
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+)
+
+// binaryData reports whether p holds a NUL byte or invalid UTF-8.
+func binaryData(p []byte) bool {
+	return bytes.IndexByte(p, 0) >= 0 || !utf8.Valid(p)
+}
+
+// binaryString is binaryData for a string.
+func binaryString(s string) bool {
+	return strings.IndexByte(s, 0) >= 0 || !utf8.ValidString(s)
+}
+
+// partialRune returns the length of the incomplete character at the end of
+// p, which may be completed by the bytes that follow.
+func partialRune(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax+1; i-- {
+		if utf8.RuneStart(p[i]) {
+			if utf8.FullRune(p[i:]) {
+				return 0
+			}
+			return len(p) - i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,37 @@
+package logrotate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestRejectBinary(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename)
+	l.RejectBinary = true
+	defer closeLog(t, l)
+
+	write(t, l, "text ünïcode\n")
+	for _, s := range []string{"nul\x00\n", "invalid \xff\n"} {
+		if n, err := l.Write([]byte(s)); n != 0 || !errors.Is(err, ErrBinaryData) {
+			t.Errorf("Write(%q) = %d, %v, want ErrBinaryData", s, n, err)
+		}
+		if n, err := l.WriteString(s); n != 0 || !errors.Is(err, ErrBinaryData) {
+			t.Errorf("WriteString(%q) = %d, %v, want ErrBinaryData", s, n, err)
+		}
+	}
+
+	// a character split between reads is whole in the end.
+	if _, err := l.ReadFrom(iotest.OneByteReader(strings.NewReader("read ü\n"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.ReadFrom(strings.NewReader("bad\x00")); !errors.Is(err, ErrBinaryData) {
+		t.Errorf("ReadFrom = %v, want ErrBinaryData", err)
+	}
+
+	if got := readFile(t, filename); got != "text ünïcode\nread ü\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build windows || plan9
+
+package logrotate
+
+// chown does nothing, file ownership is not supported.
+func (l *Logrotate) chown(f file) error {
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build !windows && !plan9
+
+package logrotate
+
+import "fmt"
+
+// chown changes the owner of the new file f to Uid and Gid with SetOwner.
+func (l *Logrotate) chown(f file) error {
+	if !l.SetOwner || (l.Uid < 0 && l.Gid < 0) {
+		return nil
+	}
+
+	uid, gid := l.Uid, l.Gid
+	if uid < 0 {
+		uid = -1
+	}
+	if gid < 0 {
+		gid = -1
+	}
+
+	err := f.Chown(uid, gid)
+	if err != nil {
+		return fmt.Errorf("logrotate: chown %q: %w", f.Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+//go:build !windows && !plan9
+
+package logrotate
+
+import (
+	"os"
+	"syscall"
+	"testing"
+)
+
+// owner returns the uid and gid of the file name.
+func owner(t *testing.T, name string) (int, int) {
+	t.Helper()
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := info.Sys().(*syscall.Stat_t)
+	return int(st.Uid), int(st.Gid)
+}
+
+func TestSetOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing the owner needs root")
+	}
+
+	for _, id := range []int{1234, 0} {
+		filename := testFilename(t)
+		if err := os.WriteFile(filename, []byte("old\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chown(filename, 4321, 4321); err != nil {
+			t.Fatal(err)
+		}
+
+		l := newLogrotate(filename, WithMaxSizeBytes(10))
+		l.SetOwner = true
+		l.Uid, l.Gid = id, id
+		write(t, l, "abc\n")
+		if uid, gid := owner(t, filename); uid != id || gid != id {
+			t.Fatalf("owner of the existing file = %d:%d, want %d:%d", uid, gid, id, id)
+		}
+
+		if err := l.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		closeLog(t, l)
+		if uid, gid := owner(t, filename); uid != id || gid != id {
+			t.Fatalf("owner of the new file = %d:%d, want %d:%d", uid, gid, id, id)
+		}
+	}
+}
+
+func TestSetOwnerNegativeKeeps(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing the owner needs root")
+	}
+
+	filename := testFilename(t)
+	l := newLogrotate(filename)
+	l.SetOwner = true
+	l.Uid, l.Gid = 1234, -1
+	write(t, l, "abc\n")
+	closeLog(t, l)
+
+	if uid, gid := owner(t, filename); uid != 1234 || gid != os.Getegid() {
+		t.Fatalf("owner = %d:%d, want 1234:%d", uid, gid, os.Getegid())
+	}
+}
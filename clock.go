@@ -0,0 +1,31 @@
+package logrotate
+
+import "time"
+
+// Clock is the source of time for backup names, retention and time based
+// rotation, so tests can control it.
+type Clock interface {
+	Now() time.Time
+}
+
+// Sleeper is implemented by a Clock which also controls waiting, like the
+// backoff of RotateRetries. Other clocks wait with time.Sleep.
+type Sleeper interface {
+	Sleep(d time.Duration)
+}
+
+// realClock is the Clock of the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// sleep waits d by the Clock of l.
+func (l *Logrotate) sleep(d time.Duration) {
+	if s, ok := l.clock.(Sleeper); ok {
+		s.Sleep(d)
+		return
+	}
+	time.Sleep(d)
+}
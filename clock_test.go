@@ -0,0 +1,58 @@
+package logrotate
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackupNameUsesClock(t *testing.T) {
+	clock := &testClock{t: time.Date(2024, 3, 1, 10, 4, 5, 0, time.Local)}
+	l := newLogrotate("/var/log/app.log", WithClock(clock))
+	if got, want := l.backupName(), "/var/log/app.log.2024-03-01T10-04-05"; got != want {
+		t.Fatalf("backupName() = %q, want %q", got, want)
+	}
+
+	clock.Advance(time.Hour)
+	if got, want := l.backupName(), "/var/log/app.log.2024-03-01T11-04-05"; got != want {
+		t.Fatalf("backupName() = %q, want %q", got, want)
+	}
+}
+
+func TestSleepUsesSleeper(t *testing.T) {
+	clock := newTestClock()
+	start := clock.Now()
+	l := newLogrotate("app.log", WithClock(clock))
+
+	l.sleep(time.Hour)
+	if got := clock.Now().Sub(start); got != time.Hour {
+		t.Fatalf("clock moved %v, want 1h", got)
+	}
+}
+
+// nowClock is a Clock without Sleep.
+type nowClock struct {
+	t *time.Time
+}
+
+func (c nowClock) Now() time.Time { return *c.t }
+
+func TestClockIntervalPastMidnight(t *testing.T) {
+	filename := testFilename(t)
+	now := time.Date(2024, 3, 1, 23, 59, 0, 0, time.Local)
+	l := newLogrotate(filename, WithClock(nowClock{&now}))
+	l.RotationInterval = 24 * time.Hour
+	defer closeLog(t, l)
+
+	write(t, l, "before\n")
+	now = now.Add(2 * time.Minute)
+	write(t, l, "after\n")
+
+	files := backupFiles(t, filepath.Dir(filename))
+	if want := "app.log.2024-03-02T00-01-00"; len(files) != 1 || files[0] != want {
+		t.Fatalf("backups = %q, want %q", files, want)
+	}
+	if got := readFile(t, filename); got != "after\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
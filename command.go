@@ -0,0 +1,61 @@
+package logrotate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+// commandCompressor is the Compressor of CompressCommand.
+type commandCompressor struct {
+	args []string
+	ext  string
+}
+
+func (c commandCompressor) Extension() string {
+	return c.ext
+}
+
+func (c commandCompressor) NewWriter(dst io.Writer) (io.WriteCloser, error) {
+	w := &commandWriter{cmd: exec.Command(c.args[0], c.args[1:]...)}
+	w.cmd.Stdout = dst
+	w.cmd.Stderr = &w.stderr
+
+	in, err := w.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	w.in = in
+
+	if err := w.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("run %q: %w", c.args[0], err)
+	}
+	return w, nil
+}
+
+// commandWriter pipes its data to a running compress command.
+type commandWriter struct {
+	cmd    *exec.Cmd
+	in     io.WriteCloser
+	stderr bytes.Buffer
+}
+
+func (w *commandWriter) Write(p []byte) (int, error) {
+	return w.in.Write(p)
+}
+
+// Close ends the input and waits for the command, which must exit with
+// status zero.
+func (w *commandWriter) Close() error {
+	w.in.Close()
+
+	err := w.cmd.Wait()
+	if err != nil {
+		if msg := bytes.TrimSpace(w.stderr.Bytes()); len(msg) > 0 {
+			return fmt.Errorf("run %q: %w: %s", w.cmd.Args[0], err, msg)
+		}
+		return fmt.Errorf("run %q: %w", w.cmd.Args[0], err)
+	}
+	return nil
+}
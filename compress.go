@@ -0,0 +1,223 @@
+package logrotate
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	compressSuffix        = ".gz"
+	tempSuffix            = ".tmp"    // of files compressed with AtomicCompress.
+	defaultCompressBuffer = 32 * 1024 // default of CompressBufferSize.
+)
+
+// Compressor compresses rotated files, for formats other than gzip.
+type Compressor interface {
+	// Extension is appended to the name of compressed backups, like ".gz".
+	Extension() string
+
+	// NewWriter returns a writer compressing into dst. Closing it must
+	// flush the compressed data but not close dst.
+	NewWriter(dst io.Writer) (io.WriteCloser, error)
+}
+
+// gzipCompressor is the default Compressor.
+type gzipCompressor struct {
+	level int
+}
+
+func (gzipCompressor) Extension() string {
+	return compressSuffix
+}
+
+func (c gzipCompressor) NewWriter(dst io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(dst, c.level)
+}
+
+// compressor returns the Compressor of l, gzip at CompressLevel unless
+// Compressor or CompressCommand is set.
+func (l *Logrotate) compressor() Compressor {
+	if l.Compressor != nil {
+		return l.Compressor
+	}
+	if len(l.CompressCommand) > 0 {
+		return commandCompressor{args: l.CompressCommand, ext: l.CompressExt}
+	}
+
+	level := l.CompressLevel
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	return gzipCompressor{level: level}
+}
+
+// compressExt returns the extension of compressed backups.
+func (l *Logrotate) compressExt() string {
+	return l.compressor().Extension()
+}
+
+// checkCompress reports an invalid compression setup before a rotation.
+func (l *Logrotate) checkCompress() error {
+	if !l.Compress || l.Compressor != nil {
+		return nil
+	}
+
+	if len(l.CompressCommand) > 0 {
+		if l.CompressExt == "" {
+			return fmt.Errorf("logrotate: no extension for compress command %q", l.CompressCommand[0])
+		}
+		return nil
+	}
+
+	if l.CompressLevel < gzip.HuffmanOnly || l.CompressLevel > gzip.BestCompression {
+		return fmt.Errorf("logrotate: invalid compression level %d", l.CompressLevel)
+	}
+	return nil
+}
+
+// compress compresses the backup file name, unless it is smaller than
+// CompressMinSize.
+func (l *Logrotate) compress(name string) error {
+	if l.compressInPlace() && !exists(name) && exists(name+l.compressExt()) {
+		// the rotation compressed it in place.
+		return nil
+	}
+
+	if l.CompressMinSize > 0 {
+		info, err := os.Stat(name)
+		if err == nil && info.Size() < l.CompressMinSize {
+			return nil
+		}
+	}
+
+	tmp := ""
+	if l.AtomicCompress {
+		tmp = name + l.compressExt() + tempSuffix
+	}
+
+	err := compressFile(l.compressor(), name, name+l.compressExt(), tmp, l.compressBufferSize())
+	if err != nil {
+		return fmt.Errorf("logrotate: compress %q: %w", name, err)
+	}
+	l.diagf("compress %q", name)
+	return nil
+}
+
+// compressExisting compresses the uncompressed backups of earlier runs with
+// CompressExisting, in background with AsyncCleanup.
+func (l *Logrotate) compressExisting() error {
+	if !l.Compress || !l.CompressExisting {
+		return nil
+	}
+
+	err := l.checkCompress()
+	if err != nil {
+		return err
+	}
+
+	list, err := l.backups()
+	if err != nil {
+		return err
+	}
+
+	for _, b := range list {
+		if !b.plain {
+			continue
+		}
+
+		if l.AsyncCleanup {
+			l.queueMill(b.path)
+			continue
+		}
+
+		err := l.compress(b.path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compressBufferSize returns CompressBufferSize, or the default of 32 KiB.
+func (l *Logrotate) compressBufferSize() int {
+	if l.CompressBufferSize <= 0 {
+		return defaultCompressBuffer
+	}
+	return l.CompressBufferSize
+}
+
+// compressFile writes src compressed by c to dst and removes src on success,
+// streaming it through a buffer of bufSize bytes. If tmp is not empty the
+// data is written to tmp, synced and then renamed to dst. A partially
+// written file is removed on failure.
+func compressFile(c Compressor, src, dst, tmp string, bufSize int) (err error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	out := dst
+	if tmp != "" {
+		out = tmp
+	}
+
+	cf, err := os.OpenFile(out, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode())
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			os.Remove(out)
+		}
+	}()
+
+	w, err := c.NewWriter(cf)
+	if err != nil {
+		cf.Close()
+		return err
+	}
+
+	// hide WriterTo of the file, so the copy goes through buf.
+	buf := make([]byte, bufSize)
+	if _, err := io.CopyBuffer(w, struct{ io.Reader }{f}, buf); err != nil {
+		w.Close()
+		cf.Close()
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		cf.Close()
+		return err
+	}
+
+	if tmp != "" {
+		// the rename must not make a partial file visible after a crash.
+		if err := cf.Sync(); err != nil {
+			cf.Close()
+			return err
+		}
+	}
+
+	if err := cf.Close(); err != nil {
+		return err
+	}
+
+	if out != dst {
+		if err := os.Rename(out, dst); err != nil {
+			return err
+		}
+	}
+
+	f.Close()
+	return os.Remove(src)
+}
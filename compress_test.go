@@ -0,0 +1,331 @@
+package logrotate
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gunzip returns the decompressed content of the gzip file name.
+func gunzip(t *testing.T, name string) string {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return string(b)
+}
+
+func TestCompressBackup(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		filename := testFilename(t)
+		l := newLogrotate(filename, WithMaxSizeBytes(10), WithCompress(true))
+		l.AsyncCleanup = async
+		write(t, l, "123456789\n")
+		write(t, l, "abc\n")
+		closeLog(t, l)
+
+		files := backupFiles(t, filepath.Dir(filename))
+		if len(files) != 1 || !strings.HasSuffix(files[0], ".gz") {
+			t.Fatalf("async %t: backups = %q, want one .gz", async, files)
+		}
+		if got := gunzip(t, filepath.Join(filepath.Dir(filename), files[0])); got != "123456789\n" {
+			t.Fatalf("async %t: backup = %q", async, got)
+		}
+		if got := readFile(t, filename); got != "abc\n" {
+			t.Fatalf("async %t: file = %q", async, got)
+		}
+	}
+}
+
+func TestCompressLevelInvalid(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithCompress(true))
+	l.CompressLevel = gzip.BestCompression + 1
+	write(t, l, "123456789\n")
+	if err := l.Rotate(); err == nil || !strings.Contains(err.Error(), "compression level") {
+		t.Fatalf("Rotate = %v, want invalid compression level", err)
+	}
+	closeLog(t, l)
+}
+
+// upperCompressor "compresses" by upper-casing, with extension ".up".
+type upperCompressor struct{}
+
+func (upperCompressor) Extension() string { return ".up" }
+
+func (upperCompressor) NewWriter(dst io.Writer) (io.WriteCloser, error) {
+	return upperWriter{dst}, nil
+}
+
+type upperWriter struct{ w io.Writer }
+
+func (u upperWriter) Write(p []byte) (int, error) {
+	return u.w.Write(bytes.ToUpper(p))
+}
+
+func (upperWriter) Close() error { return nil }
+
+func TestCustomCompressor(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithCompress(true))
+	l.Compressor = upperCompressor{}
+	write(t, l, "abcdefghi\n")
+	write(t, l, "next\n")
+	closeLog(t, l)
+
+	files := backupFiles(t, filepath.Dir(filename))
+	if len(files) != 1 || filepath.Ext(files[0]) != ".up" {
+		t.Fatalf("backups = %q, want one .up", files)
+	}
+	if got := readFile(t, filepath.Join(filepath.Dir(filename), files[0])); got != "ABCDEFGHI\n" {
+		t.Fatalf("backup = %q", got)
+	}
+}
+
+func TestCompressMinSize(t *testing.T) {
+	filename := testFilename(t)
+	dir := filepath.Dir(filename)
+	clock := newTestClock()
+	l := newLogrotate(filename, WithMaxSizeBytes(100), WithMaxBackups(2), WithCompress(true), WithClock(clock))
+	l.CompressMinSize = 50
+	write(t, l, "tiny\n")
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	write(t, l, strings.Repeat("large\n", 10))
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	closeLog(t, l)
+
+	files := backupFiles(t, dir)
+	if len(files) != 2 || strings.HasSuffix(files[0], ".gz") == strings.HasSuffix(files[1], ".gz") {
+		t.Fatalf("backups = %q, want one plain and one .gz", files)
+	}
+
+	// both count toward MaxBackups, the tiny one is the oldest.
+	clock.Advance(time.Minute)
+	l = newLogrotate(filename, WithMaxSizeBytes(100), WithMaxBackups(2), WithCompress(true), WithClock(clock))
+	l.CompressMinSize = 50
+	write(t, l, strings.Repeat("large\n", 10))
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	closeLog(t, l)
+	files = backupFiles(t, dir)
+	if len(files) != 2 {
+		t.Fatalf("backups = %q, want 2", files)
+	}
+	for _, name := range files {
+		if !strings.HasSuffix(name, ".gz") {
+			t.Fatalf("backup %s kept, want the tiny one removed", name)
+		}
+	}
+}
+
+func TestCompressInline(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithCompress(true))
+	defer closeLog(t, l)
+
+	write(t, l, "123456789\n")
+	write(t, l, "abc\n")
+	files := backupFiles(t, filepath.Dir(filename))
+	if len(files) != 1 || !strings.HasSuffix(files[0], ".gz") {
+		t.Fatalf("backups right after the Write = %q, want one .gz", files)
+	}
+}
+
+// nameCompressor records the names of the files it writes to.
+type nameCompressor struct {
+	names *[]string
+}
+
+func (nameCompressor) Extension() string { return ".gz" }
+
+func (c nameCompressor) NewWriter(dst io.Writer) (io.WriteCloser, error) {
+	*c.names = append(*c.names, dst.(interface{ Name() string }).Name())
+	return gzip.NewWriter(dst), nil
+}
+
+func TestAtomicCompress(t *testing.T) {
+	filename := testFilename(t)
+	dir := filepath.Dir(filename)
+	var names []string
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithCompress(true), WithClock(newTestClock()))
+	l.AtomicCompress = true
+	l.Compressor = nameCompressor{&names}
+	write(t, l, "123456789\n")
+	write(t, l, "abc\n")
+
+	// a stray temporary file is no backup.
+	stray := filepath.Join(dir, "app.log.2024-01-01T00-00-00.gz.tmp")
+	if err := os.WriteFile(stray, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	list, err := l.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	closeLog(t, l)
+	os.Remove(stray)
+
+	if len(names) != 1 || !strings.HasSuffix(names[0], ".gz.tmp") {
+		t.Fatalf("compressed to %q, want a temporary name", names)
+	}
+	if len(list) != 1 || list[0].Path != strings.TrimSuffix(names[0], ".tmp") {
+		t.Fatalf("Backups() = %+v, want only the compressed backup", list)
+	}
+	files := backupFiles(t, dir)
+	if len(files) != 1 || !strings.HasSuffix(files[0], ".gz") {
+		t.Fatalf("backups = %q, want one .gz and no temporary file", files)
+	}
+	if got := gunzip(t, filepath.Join(dir, files[0])); got != "123456789\n" {
+		t.Fatalf("backup = %q", got)
+	}
+}
+
+func TestCompressOnClose(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithCompress(true))
+	l.CompressOnClose = true
+	write(t, l, "short job\n")
+	closeLog(t, l)
+
+	if names := dirNames(t, filepath.Dir(filename)); len(names) != 1 || names[0] != "app.log.gz" {
+		t.Fatalf("files = %q, want only app.log.gz", names)
+	}
+	if got := gunzip(t, filename+".gz"); got != "short job\n" {
+		t.Fatalf("compressed file = %q", got)
+	}
+}
+
+func TestCompressExisting(t *testing.T) {
+	filename := testFilename(t)
+	dir := filepath.Dir(filename)
+	clock := newTestClock()
+	for h := 3; h >= 1; h-- {
+		writeBackup(t, filename, clock.Now().Add(-time.Duration(h)*time.Hour), "old\n")
+	}
+
+	l := newLogrotate(filename, WithCompress(true), WithClock(clock))
+	l.CompressExisting = true
+	write(t, l, "new\n")
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	closeLog(t, l)
+
+	files := backupFiles(t, dir)
+	if len(files) != 4 {
+		t.Fatalf("backups = %q, want 4", files)
+	}
+	for _, name := range files {
+		if !strings.HasSuffix(name, ".gz") {
+			t.Errorf("backup %s not compressed", name)
+		}
+	}
+}
+
+func TestCompressCommand(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat not found")
+	}
+	for _, tc := range []struct {
+		args []string
+		ok   bool
+	}{
+		{[]string{"cat"}, true},
+		{[]string{"false"}, false},
+		{[]string{"logrotate-no-such-command"}, false},
+	} {
+		filename := testFilename(t)
+		dir := filepath.Dir(filename)
+		l := newLogrotate(filename, WithMaxSizeBytes(10), WithCompress(true))
+		l.CompressCommand = tc.args
+		l.CompressExt = ".cat"
+		write(t, l, "123456789\n")
+		_, err := l.Write([]byte("abc\n"))
+		closeLog(t, l)
+		if tc.ok != (err == nil) {
+			t.Fatalf("%q: Write = %v", tc.args, err)
+		}
+
+		files := backupFiles(t, dir)
+		if len(files) != 1 {
+			t.Fatalf("%q: backups = %q, want 1", tc.args, files)
+		}
+		if got := filepath.Ext(files[0]) == ".cat"; got != tc.ok {
+			t.Fatalf("%q: backup = %s, compressed %t want %t", tc.args, files[0], got, tc.ok)
+		}
+		if got := readFile(t, filepath.Join(dir, files[0])); got != "123456789\n" {
+			t.Fatalf("%q: backup = %q", tc.args, got)
+		}
+	}
+}
+
+// compressAlloc returns the bytes allocated to compress a file of size
+// bytes.
+func compressAlloc(t testing.TB, size int) uint64 {
+	t.Helper()
+	dir := t.TempDir()
+	src := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(src, []byte(strings.Repeat(benchmarkLine, size/len(benchmarkLine))), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if err := compressFile(gzipCompressor{level: gzip.DefaultCompression}, src, src+".gz", "", 32<<10); err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+func TestCompressStreams(t *testing.T) {
+	small := compressAlloc(t, 1<<20)
+	large := compressAlloc(t, 32<<20)
+
+	// reading the file into memory would take 31 MiB more.
+	if large > small+1<<20 {
+		t.Fatalf("compressing 32 MiB allocated %d bytes, 1 MiB %d", large, small)
+	}
+}
+
+func BenchmarkCompress(b *testing.B) {
+	dir := b.TempDir()
+	src := filepath.Join(dir, "app.log")
+	data := []byte(strings.Repeat(benchmarkLine, (8<<20)/len(benchmarkLine)))
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := os.WriteFile(src, data, 0644); err != nil {
+			b.Fatal(err)
+		}
+		if err := compressFile(gzipCompressor{level: gzip.DefaultCompression}, src, src+".gz", "", 32<<10); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
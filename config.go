@@ -0,0 +1,124 @@
+package logrotate
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Config is the JSON form of the main Logrotate settings. Sizes are strings
+// accepted by ParseSize, like "100MB", and durations are Go duration
+// strings, like "168h" or "30m".
+type Config struct {
+	Filename         string   `json:"filename"`
+	MaxSize          string   `json:"max_size"`
+	MaxBackups       int      `json:"max_backups"`
+	MaxAge           Duration `json:"max_age"`
+	KeepMinimum      int      `json:"keep_minimum"`
+	MaxTotalSize     string   `json:"max_total_size"`
+	Compress         bool     `json:"compress"`
+	CompressLevel    int      `json:"compress_level"`
+	AsyncCleanup     bool     `json:"async_cleanup"`
+	RotationInterval Duration `json:"rotation_interval"`
+	BackupTimeFormat string   `json:"backup_time_format"`
+	UTC              bool     `json:"utc"`
+	Symlink          string   `json:"symlink"`
+	BufferSize       int      `json:"buffer_size"`
+	MaxLines         int      `json:"max_lines"`
+	ArchiveDir       string   `json:"archive_dir"`
+	CronSchedule     string   `json:"cron_schedule"`
+}
+
+// Duration is a time.Duration read from a JSON string like "24h", or from
+// a number of nanoseconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case float64:
+		*d = Duration(v)
+	case string:
+		t, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("logrotate: invalid duration %q", v)
+		}
+		*d = Duration(t)
+	default:
+		return fmt.Errorf("logrotate: invalid duration %s", b)
+	}
+
+	return nil
+}
+
+// NewFromConfig returns a Logrotate configured by cfg, or an error if cfg
+// is invalid. Empty sizes and zero values keep the defaults.
+func NewFromConfig(cfg Config) (*Logrotate, error) {
+	if cfg.Filename == "" {
+		return nil, fmt.Errorf("logrotate: config has no filename")
+	}
+
+	l := newLogrotate(cfg.Filename)
+
+	if cfg.MaxSize != "" {
+		n, err := ParseSize(cfg.MaxSize)
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			l.MaxSize = n
+		}
+	}
+
+	if cfg.MaxTotalSize != "" {
+		n, err := ParseSize(cfg.MaxTotalSize)
+		if err != nil {
+			return nil, err
+		}
+		l.MaxTotalSize = n
+	}
+
+	switch {
+	case cfg.MaxBackups < 0:
+		return nil, fmt.Errorf("logrotate: negative max_backups %d", cfg.MaxBackups)
+	case cfg.KeepMinimum < 0:
+		return nil, fmt.Errorf("logrotate: negative keep_minimum %d", cfg.KeepMinimum)
+	case cfg.MaxAge < 0:
+		return nil, fmt.Errorf("logrotate: negative max_age %s", time.Duration(cfg.MaxAge))
+	case cfg.RotationInterval < 0:
+		return nil, fmt.Errorf("logrotate: negative rotation_interval %s", time.Duration(cfg.RotationInterval))
+	case cfg.BufferSize < 0:
+		return nil, fmt.Errorf("logrotate: negative buffer_size %d", cfg.BufferSize)
+	case cfg.MaxLines < 0:
+		return nil, fmt.Errorf("logrotate: negative max_lines %d", cfg.MaxLines)
+	}
+
+	l.MaxBackups = cfg.MaxBackups
+	l.MaxAge = time.Duration(cfg.MaxAge)
+	l.KeepMinimum = cfg.KeepMinimum
+	l.Compress = cfg.Compress
+	l.CompressLevel = cfg.CompressLevel
+	l.AsyncCleanup = cfg.AsyncCleanup
+	l.RotationInterval = time.Duration(cfg.RotationInterval)
+	l.BackupTimeFormat = cfg.BackupTimeFormat
+	l.UTC = cfg.UTC
+	l.Symlink = cfg.Symlink
+	l.BufferSize = cfg.BufferSize
+	l.MaxLines = cfg.MaxLines
+	l.ArchiveDir = cfg.ArchiveDir
+	l.CronSchedule = cfg.CronSchedule
+
+	if err := l.checkRotate(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
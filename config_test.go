@@ -0,0 +1,61 @@
+package logrotate
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewFromConfig(t *testing.T) {
+	filename := testFilename(t)
+	blob := `{
+		"filename": ` + strconv.Quote(filename) + `,
+		"max_size": "10KB",
+		"max_backups": 3,
+		"max_age": "168h",
+		"compress": true,
+		"rotation_interval": 3600000000000
+	}`
+	var cfg Config
+	if err := json.Unmarshal([]byte(blob), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeLog(t, l)
+
+	if l.Filename != filename || l.MaxSize != 10<<10 || l.MaxBackups != 3 || l.MaxAge != 168*time.Hour ||
+		!l.Compress || l.RotationInterval != time.Hour {
+		t.Fatalf("config gives %+v", l)
+	}
+	write(t, l, "abc\n")
+	if got := readFile(t, filename); got != "abc\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
+
+func TestNewFromConfigInvalid(t *testing.T) {
+	for _, blob := range []string{
+		`{"filename": "app.log", "max_age": "a week"}`,
+		`{"filename": "app.log", "max_age": true}`,
+	} {
+		var cfg Config
+		if err := json.Unmarshal([]byte(blob), &cfg); err == nil {
+			t.Errorf("%s: no error", blob)
+		}
+	}
+
+	for _, cfg := range []Config{
+		{},
+		{Filename: "app.log", MaxSize: "ten"},
+		{Filename: "app.log", MaxAge: Duration(-time.Hour)},
+	} {
+		if _, err := NewFromConfig(cfg); err == nil || !strings.HasPrefix(err.Error(), "logrotate: ") {
+			t.Errorf("NewFromConfig(%+v) = %v, want an error", cfg, err)
+		}
+	}
+}
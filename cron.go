@@ -0,0 +1,195 @@
+package logrotate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed CronSchedule. Each field is a bit set of the
+// values it matches.
+type cronSchedule struct {
+	spec                          string
+	minute, hour, dom, month, dow uint64
+	domAll, dowAll                bool // the field was "*", for the day rule.
+}
+
+// cronFields are the fields of a cron line with their ranges.
+var cronFields = []struct {
+	name   string
+	lo, hi int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// parseCron parses a cron line of five fields, minute, hour, day of month,
+// month and day of week, like "0 2 * * *" or "30 */6 * * 1-5". A field is
+// "*" or a comma list of numbers and ranges like "1-5", each optionally
+// followed by a step like "/2". Sunday is 0 or 7.
+func parseCron(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("logrotate: invalid cron schedule %q: want %d fields", spec, len(cronFields))
+	}
+
+	var sets [5]uint64
+	for i, f := range cronFields {
+		set, err := parseCronField(fields[i], f.lo, f.hi)
+		if err != nil {
+			return nil, fmt.Errorf("logrotate: invalid cron schedule %q: %s: %w", spec, f.name, err)
+		}
+		sets[i] = set
+	}
+
+	dow := sets[4]
+	if dow&(1<<7) != 0 {
+		dow = dow&^(1<<7) | 1
+	}
+
+	return &cronSchedule{
+		spec:   spec,
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    dow,
+		domAll: strings.HasPrefix(fields[2], "*"),
+		dowAll: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// parseCronField returns the bit set of the values matched by field.
+func parseCronField(field string, lo, hi int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step, hasStep := strings.Cut(part, "/")
+
+		start, end := lo, hi
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if start, err = cronValue(a, lo, hi); err != nil {
+				return 0, err
+			}
+			if end, err = cronValue(b, lo, hi); err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		default:
+			v, err := cronValue(rng, lo, hi)
+			if err != nil {
+				return 0, err
+			}
+			start = v
+			if !hasStep {
+				end = v
+			}
+		}
+
+		n := 1
+		if hasStep {
+			var err error
+			n, err = strconv.Atoi(step)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q", step)
+			}
+		}
+
+		for v := start; v <= end; v += n {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// cronValue parses a number of a cron field from lo to hi.
+func cronValue(s string, lo, hi int) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < lo || v > hi {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return v, nil
+}
+
+// matchDay reports whether the day of t is scheduled. If both day fields
+// are restricted either one matching is enough, as in cron.
+func (s *cronSchedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<t.Weekday()) != 0
+	if s.domAll || s.dowAll {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first scheduled minute after t in the location of t, or
+// the zero time if there is none within five years, like for February 30.
+func (s *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + 5
+
+	for t.Year() <= limit {
+		year, month, day := t.Date()
+		loc := t.Location()
+		switch {
+		case s.month&(1<<month) == 0:
+			t = later(t, time.Date(year, month+1, 1, 0, 0, 0, 0, loc))
+		case !s.matchDay(t):
+			t = later(t, time.Date(year, month, day+1, 0, 0, 0, 0, loc))
+		case s.hour&(1<<t.Hour()) == 0:
+			// in local time, for zones with offsets of half an hour.
+			t = later(t, time.Date(year, month, day, t.Hour()+1, 0, 0, 0, loc))
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// later returns next, or the minute after t if next is not after t, which
+// happens for a local time skipped by a daylight saving change.
+func later(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Add(time.Minute)
+}
+
+// cronPassed reports whether a time of CronSchedule passed since a file
+// was opened at openTime. The next time is kept for the following calls,
+// unless probe is set. An invalid CronSchedule is reported by checkRotate.
+func (l *Logrotate) cronPassed(openTime time.Time, probe bool) bool {
+	if l.CronSchedule == "" {
+		return false
+	}
+
+	s, from, next := l.cron, l.cronFrom, l.cronNext
+	if s == nil || s.spec != l.CronSchedule {
+		var err error
+		s, err = parseCron(l.CronSchedule)
+		if err != nil {
+			return false
+		}
+		from = time.Time{}
+	}
+
+	if from.IsZero() || !from.Equal(openTime) {
+		from = openTime
+		next = s.next(openTime.In(l.timeNow().Location()))
+	}
+	if !probe {
+		l.cron, l.cronFrom, l.cronNext = s, from, next
+	}
+	return !next.IsZero() && !l.timeNow().Before(next)
+}
@@ -0,0 +1,82 @@
+package logrotate
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	tests := []struct {
+		spec       string
+		from, want time.Time
+	}{
+		{"0 2 * * *", time.Date(2024, 1, 1, 1, 30, 0, 0, ist), time.Date(2024, 1, 1, 2, 0, 0, 0, ist)},
+		{"0 2 * * *", time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC), time.Date(2024, 1, 2, 2, 0, 0, 0, time.UTC)},
+		{"0 0 * * 1", time.Date(2024, 1, 3, 5, 0, 0, 0, time.UTC), time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 3, 5, 7, 10, 0, time.UTC), time.Date(2024, 1, 3, 5, 15, 0, 0, time.UTC)},
+		{"0 0 1 * 7", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Time{}},
+	}
+
+	// 02:30 is skipped by the change to daylight saving time.
+	if ny, err := time.LoadLocation("America/New_York"); err == nil {
+		tests = append(tests, struct {
+			spec       string
+			from, want time.Time
+		}{"30 2 * * *", time.Date(2024, 3, 10, 1, 0, 0, 0, ny), time.Date(2024, 3, 11, 2, 30, 0, 0, ny)})
+	}
+
+	for _, tt := range tests {
+		s, err := parseCron(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%q after %v = %v, want %v", tt.spec, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "a * * * *", "5-1 * * * *", "*/0 * * * *", "* * 0 * *"} {
+		if _, err := parseCron(spec); err == nil || !strings.Contains(err.Error(), "invalid cron schedule") {
+			t.Errorf("parseCron(%q) = %v, want an invalid cron schedule", spec, err)
+		}
+	}
+}
+
+func TestCronSchedule(t *testing.T) {
+	filename := testFilename(t)
+	clock := &testClock{t: time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)}
+	l := newLogrotate(filename, WithClock(clock), WithUTC(true))
+	l.CronSchedule = "0 2 * * *"
+
+	// three days of writes every ten minutes cross three times of 02:00.
+	for i := 0; i < 3*24*6; i++ {
+		write(t, l, "x\n")
+		clock.Advance(10 * time.Minute)
+	}
+	if got := l.Rotations(); got != 3 {
+		t.Fatalf("Rotations() = %d, want one per day", got)
+	}
+	want := []string{"app.log.2024-01-01T02-00-00", "app.log.2024-01-02T02-00-00", "app.log.2024-01-03T02-00-00"}
+	got := backupFiles(t, filepath.Dir(filename))
+	if len(got) != len(want) {
+		t.Fatalf("backups = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("backups = %q, want %q", got, want)
+		}
+	}
+
+	l.CronSchedule = "bad"
+	if err := l.Rotate(); err == nil {
+		t.Fatal("Rotate with an invalid CronSchedule succeeded")
+	}
+	closeLog(t, l)
+}
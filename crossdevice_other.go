@@ -0,0 +1,17 @@
+//go:build windows || plan9
+
+package logrotate
+
+// renameOpen is false, open files can not be renamed for FastRotate.
+const renameOpen = false
+
+// crossDevice reports false, renames across volumes are done by the system.
+func crossDevice(err error) bool {
+	return false
+}
+
+// transient reports true, errors like sharing violations are not told
+// apart from lasting ones.
+func transient(err error) bool {
+	return true
+}
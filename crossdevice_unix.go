@@ -0,0 +1,21 @@
+//go:build !windows && !plan9
+
+package logrotate
+
+import (
+	"errors"
+	"syscall"
+)
+
+// renameOpen is true, open files can be renamed for FastRotate.
+const renameOpen = true
+
+// crossDevice reports whether err is a rename across file systems.
+func crossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
+
+// transient reports whether err may go away when retried.
+func transient(err error) bool {
+	return errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR)
+}
@@ -0,0 +1,61 @@
+package logrotate
+
+import (
+	"fmt"
+	"sync"
+)
+
+// diagnostics is the ring of the last messages kept with WithDiagnostics.
+type diagnostics struct {
+	mu   sync.Mutex
+	msgs []string
+	next int // index of the oldest message once msgs is full.
+	size int
+}
+
+// WithDiagnostics keeps the last n messages about opening, rotating,
+// compressing and removing files, and about errors, for Diagnostics.
+func WithDiagnostics(n int) Option {
+	return func(l *Logrotate) {
+		if n > 0 {
+			l.diag = &diagnostics{size: n}
+		}
+	}
+}
+
+// Diagnostics returns the messages kept with WithDiagnostics from oldest to
+// newest, or nil without it.
+func (l *Logrotate) Diagnostics() []string {
+	d := l.diag
+	if d == nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	msgs := make([]string, 0, len(d.msgs))
+	msgs = append(msgs, d.msgs[d.next:]...)
+	return append(msgs, d.msgs[:d.next]...)
+}
+
+// diagf adds a message for Diagnostics, stamped by the Clock. It may be
+// called without mu held.
+func (l *Logrotate) diagf(format string, args ...any) {
+	d := l.diag
+	if d == nil {
+		return
+	}
+
+	msg := l.timeNow().Format("2006-01-02T15:04:05.000 ") + fmt.Sprintf(format, args...)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.msgs) < d.size {
+		d.msgs = append(d.msgs, msg)
+		return
+	}
+	d.msgs[d.next] = msg
+	d.next = (d.next + 1) % d.size
+}
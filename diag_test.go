@@ -0,0 +1,68 @@
+package logrotate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// diagVerbs returns the first word of each message after its time stamp.
+func diagVerbs(msgs []string) []string {
+	var verbs []string
+	for _, m := range msgs {
+		f := strings.Fields(m)
+		verbs = append(verbs, strings.TrimSuffix(f[1], ":"))
+	}
+	return verbs
+}
+
+func TestDiagnostics(t *testing.T) {
+	clock := newTestClock()
+	l := newLogrotate(testFilename(t), WithMaxBackups(1), WithClock(clock), WithDiagnostics(10))
+	defer closeLog(t, l)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			l.Diagnostics()
+		}
+	}()
+	for i := 0; i < 2; i++ {
+		write(t, l, "x\n")
+		if err := l.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Minute)
+	}
+	<-done
+
+	msgs := l.Diagnostics()
+	// a rotation is logged once the new file is open.
+	want := []string{"open", "open", "rotate", "open", "rotate", "remove"}
+	if got := diagVerbs(msgs); !reflect.DeepEqual(got, want) {
+		t.Fatalf("diagnostics = %q, want %q", msgs, want)
+	}
+	if !strings.HasPrefix(msgs[0], "2024-03-01T10:00:00.000 ") {
+		t.Fatalf("diagnostics[0] = %q, want the time of the Clock", msgs[0])
+	}
+}
+
+func TestDiagnosticsRing(t *testing.T) {
+	l := newLogrotate(testFilename(t), WithClock(newTestClock()), WithDiagnostics(2))
+	defer closeLog(t, l)
+	if got := newLogrotate(testFilename(t)).Diagnostics(); got != nil {
+		t.Fatalf("Diagnostics() = %q without WithDiagnostics", got)
+	}
+
+	for i := 0; i < 3; i++ {
+		write(t, l, "x\n")
+		if err := l.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := diagVerbs(l.Diagnostics()), []string{"open", "rotate"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("diagnostics = %q, want the newest %q", l.Diagnostics(), want)
+	}
+}
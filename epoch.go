@@ -0,0 +1,26 @@
+package logrotate
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// epochSep separates the EpochFunc token from the timestamp of a backup.
+const epochSep = '@'
+
+// epochChanged reports whether EpochFunc returns another token than the one
+// of the current file.
+func (l *Logrotate) epochChanged() bool {
+	return l.EpochFunc != nil && l.EpochFunc() != l.epoch
+}
+
+// epochSuffix returns the part of the backup name for the token of the
+// current file, empty without one.
+func (l *Logrotate) epochSuffix() string {
+	if l.EpochFunc == nil || l.epoch == "" {
+		return ""
+	}
+
+	r := strings.NewReplacer("/", "_", string(filepath.Separator), "_", string(epochSep), "_")
+	return string(epochSep) + r.Replace(l.epoch)
+}
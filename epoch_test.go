@@ -0,0 +1,47 @@
+package logrotate
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEpochFunc(t *testing.T) {
+	filename := testFilename(t)
+	epoch := "v1"
+	l := newLogrotate(filename, WithClock(newTestClock()))
+	l.EpochFunc = func() string { return epoch }
+	l.InstanceID = "h1"
+
+	write(t, l, "a\n")
+	write(t, l, "b\n")
+	epoch = "v/2"
+	write(t, l, "c\n")
+	epoch = "v3"
+	write(t, l, "d\n")
+	closeLog(t, l)
+
+	// each backup is named by the epoch it was written in, the sequence of
+	// the same second counts across epochs.
+	want := []string{"app.log.2024-03-01T10-00-00.1@v_2.h1", "app.log.2024-03-01T10-00-00@v1.h1"}
+	if got := backupFiles(t, filepath.Dir(filename)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("backups = %q, want %q", got, want)
+	}
+	if got := backupContents(t, filepath.Dir(filename)); !reflect.DeepEqual(got, []string{"c\n", "a\nb\n"}) {
+		t.Fatalf("backup contents = %q", got)
+	}
+	if got := readFile(t, filename); got != "d\n" {
+		t.Fatalf("file = %q", got)
+	}
+
+	// the retention finds backups with epochs.
+	l = newLogrotate(filename, WithClock(newTestClock()), WithMaxBackups(1))
+	l.InstanceID = "h1"
+	if err := l.ApplyRetention(); err != nil {
+		t.Fatal(err)
+	}
+	closeLog(t, l)
+	if got := backupFiles(t, filepath.Dir(filename)); !reflect.DeepEqual(got, want[:1]) {
+		t.Fatalf("backups = %q, want %q", got, want[:1])
+	}
+}
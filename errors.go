@@ -0,0 +1,35 @@
+package logrotate
+
+import "errors"
+
+// ErrFilenameIsDir is returned, wrapped with the path, when Filename is an
+// existing directory.
+var ErrFilenameIsDir = errors.New("logrotate: filename is a directory")
+
+// ErrNoFilename is returned when a file is opened with an empty Filename.
+var ErrNoFilename = errors.New("logrotate: no filename")
+
+// ErrBackupIsDir is returned, wrapped with the path, when the name of the
+// next backup is taken by a directory, in IndexMode or with BackupNameFunc.
+// Timestamped names skip a taken name instead. A Write reports it to
+// ErrorHandler and continues in the current file, which is rotated once the
+// directory is gone.
+var ErrBackupIsDir = errors.New("logrotate: backup path is a directory")
+
+// ErrOverTotalSize is reported, wrapped with the path, when the newest backup
+// alone, or the KeepMinimum newest, exceed MaxTotalSize. They are kept, and
+// the older ones are removed. ApplyRetention and Drain return it.
+var ErrOverTotalSize = errors.New("logrotate: backup exceeds max total size")
+
+// ErrWriteTimeout is returned, wrapped, when a write takes longer than
+// WriteTimeout.
+var ErrWriteTimeout = errors.New("logrotate: write timeout")
+
+// ErrBinaryData is returned, wrapped with the path, for a write rejected by
+// RejectBinary.
+var ErrBinaryData = errors.New("logrotate: binary data")
+
+// ErrRetentionExceeded is returned, wrapped with the path, when a rotation
+// with FailOnExceed would exceed MaxBackups. A Write reports it to
+// ErrorHandler and continues in the current file.
+var ErrRetentionExceeded = errors.New("logrotate: max backups reached")
@@ -0,0 +1,153 @@
+package logrotate
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorsWrapOSErrors(t *testing.T) {
+	dir := t.TempDir()
+	notDir := filepath.Join(dir, "file")
+	if err := os.WriteFile(notDir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		l      *Logrotate
+		prefix string
+	}{
+		{"mkdir", &Logrotate{Filename: filepath.Join(notDir, "sub", "app.log")}, "logrotate: mkdir "},
+		{"open", &Logrotate{Filename: filepath.Join(dir, strings.Repeat("x", 300))}, "logrotate: open "},
+	}
+	for _, tt := range tests {
+		_, err := tt.l.Write([]byte("x"))
+		if err == nil {
+			t.Fatalf("%s: Write succeeded", tt.name)
+		}
+		if !strings.HasPrefix(err.Error(), tt.prefix) || !strings.Contains(err.Error(), dir) {
+			t.Errorf("%s: error %q does not start with %q and name the path", tt.name, err, tt.prefix)
+		}
+		var pathErr *fs.PathError
+		if !errors.As(err, &pathErr) {
+			t.Errorf("%s: error %q does not wrap the *fs.PathError", tt.name, err)
+		}
+	}
+}
+
+func TestErrorsWrapRotation(t *testing.T) {
+	filename := testFilename(t)
+	notDir := filepath.Join(filepath.Dir(filename), "archive")
+	if err := os.WriteFile(notDir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l := NewLogrotateT(filename, 10)
+	l.ArchiveDir = notDir
+	defer closeLog(t, l)
+
+	write(t, l, "x\n")
+	err := l.Rotate()
+	var pathErr *fs.PathError
+	if err == nil || !strings.HasPrefix(err.Error(), "logrotate: mkdir ") || !errors.As(err, &pathErr) {
+		t.Fatalf("Rotate = %v, want a wrapped mkdir error", err)
+	}
+}
+
+func TestErrFilenameIsDir(t *testing.T) {
+	dir := t.TempDir()
+	l := newLogrotate(dir)
+	defer l.Close()
+
+	_, err := l.Write([]byte("abc\n"))
+	if !errors.Is(err, ErrFilenameIsDir) || !strings.Contains(err.Error(), dir) {
+		t.Fatalf("Write = %v, want ErrFilenameIsDir with the path", err)
+	}
+}
+
+func TestErrNoFilename(t *testing.T) {
+	l := &Logrotate{}
+	defer l.Close()
+
+	if _, err := l.Write([]byte("abc\n")); !errors.Is(err, ErrNoFilename) {
+		t.Fatalf("Write = %v, want ErrNoFilename", err)
+	}
+}
+
+func TestNoCreateDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+	l := newLogrotate(filepath.Join(dir, "app.log"))
+	l.NoCreateDir = true
+
+	_, err := l.Write([]byte("abc\n"))
+	if !errors.Is(err, fs.ErrNotExist) || !strings.Contains(err.Error(), "NoCreateDir") {
+		t.Fatalf("Write = %v, want a missing directory error", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("directory created with NoCreateDir: %v", err)
+	}
+
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write(t, l, "abc\n")
+	closeLog(t, l)
+}
+
+func TestErrBackupIsDir(t *testing.T) {
+	filename := testFilename(t)
+	taken := filename + ".old"
+	var handled []error
+	l := newLogrotate(filename, WithMaxSizeBytes(10))
+	l.BackupNameFunc = func(name string, _ time.Time) string { return name + ".old" }
+	l.ErrorHandler = func(err error) { handled = append(handled, err) }
+	defer closeLog(t, l)
+
+	if err := os.Mkdir(taken, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write(t, l, "123456789\n")
+	write(t, l, "abc\n")
+	if len(handled) != 1 || !errors.Is(handled[0], ErrBackupIsDir) {
+		t.Fatalf("ErrorHandler got %v, want ErrBackupIsDir", handled)
+	}
+	if got := readFile(t, filename); got != "123456789\nabc\n" {
+		t.Fatalf("file = %q, want the writes kept in the current file", got)
+	}
+	if err := l.Rotate(); !errors.Is(err, ErrBackupIsDir) || !strings.Contains(err.Error(), taken) {
+		t.Fatalf("Rotate = %v, want ErrBackupIsDir with the path", err)
+	}
+
+	if err := os.Remove(taken); err != nil {
+		t.Fatal(err)
+	}
+	write(t, l, "def\n")
+	if got := readFile(t, taken); got != "123456789\nabc\n" {
+		t.Fatalf("backup = %q", got)
+	}
+	if got := readFile(t, filename); got != "def\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
+
+func TestBackupNameSkipsDir(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(clock))
+	defer closeLog(t, l)
+
+	taken := filename + "." + clock.Now().Format(backupTimeFormat)
+	if err := os.Mkdir(taken, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write(t, l, "123456789\n")
+	write(t, l, "abc\n")
+	if got := readFile(t, taken+".1"); got != "123456789\n" {
+		t.Fatalf("backup = %q, want it next to the directory", got)
+	}
+}
@@ -0,0 +1,66 @@
+package logrotate
+
+import "time"
+
+// RotateReason tells why a file was rotated.
+type RotateReason int
+
+const (
+	// RotateSize is a rotation at MaxSize.
+	RotateSize RotateReason = iota
+
+	// RotateLines is a rotation at MaxLines.
+	RotateLines
+
+	// RotateTime is a rotation at RotationInterval, MaxOpenDuration or
+	// CronSchedule.
+	RotateTime
+
+	// RotateManual is a rotation by Rotate.
+	RotateManual
+
+	// RotateStartup is a rotation by RotateExistingMode.
+	RotateStartup
+
+	// RotateDiskSpace is a rotation at MinFreeDisk.
+	RotateDiskSpace
+
+	// RotateCustom is a rotation by ShouldRotate.
+	RotateCustom
+
+	// RotateEpoch is a rotation at a new token of EpochFunc.
+	RotateEpoch
+)
+
+var reasonNames = [...]string{"size", "lines", "time", "manual", "startup", "disk space", "custom", "epoch"}
+
+func (r RotateReason) String() string {
+	if r < 0 || int(r) >= len(reasonNames) {
+		return "unknown"
+	}
+	return reasonNames[r]
+}
+
+// RotationEvent describes a rotation, sent to the channel of WithEventChan.
+type RotationEvent struct {
+	OldPath string    // of the backup holding the rotated data.
+	NewPath string    // of the new active file.
+	Size    int64     // of the rotated file in bytes.
+	Time    time.Time // of the rotation by the Clock.
+	Reason  RotateReason
+}
+
+// sendEvents sends events to the channel of WithEventChan without blocking,
+// an event which does not fit is dropped.
+func (l *Logrotate) sendEvents(events []RotationEvent) {
+	if l.events == nil {
+		return
+	}
+
+	for _, e := range events {
+		select {
+		case l.events <- e:
+		default:
+		}
+	}
+}
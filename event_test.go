@@ -0,0 +1,58 @@
+package logrotate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventChan(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	ch := make(chan RotationEvent, 1)
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(clock), WithEventChan(ch))
+	defer closeLog(t, l)
+
+	write(t, l, "123456789\n")
+	write(t, l, "abc\n")
+	e := <-ch
+	want := RotationEvent{
+		OldPath: filename + "." + clock.Now().Format(backupTimeFormat),
+		NewPath: filename,
+		Size:    10,
+		Time:    clock.Now(),
+		Reason:  RotateSize,
+	}
+	if e != want {
+		t.Fatalf("event = %+v, want %+v", e, want)
+	}
+
+	clock.Advance(time.Minute)
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if e := <-ch; e.Reason != RotateManual || e.Size != 4 {
+		t.Fatalf("event = %+v, want a manual rotation of 4 bytes", e)
+	}
+
+	// a full channel drops the event instead of blocking.
+	ch <- RotationEvent{}
+	clock.Advance(time.Minute)
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	<-ch
+	select {
+	case e := <-ch:
+		t.Fatalf("event %+v not dropped", e)
+	default:
+	}
+}
+
+func TestRotateReasonString(t *testing.T) {
+	if got := RotateDiskSpace.String(); got != "disk space" {
+		t.Errorf("RotateDiskSpace = %q", got)
+	}
+	if got := RotateReason(-1).String(); got != "unknown" {
+		t.Errorf("RotateReason(-1) = %q", got)
+	}
+}
@@ -0,0 +1,52 @@
+package logrotate
+
+import "time"
+
+// startFlusher starts the background goroutine flushing the buffer every
+// FlushInterval. It must be called with mu held.
+func (l *Logrotate) startFlusher() {
+	if l.FlushInterval <= 0 || l.BufferSize <= 0 || l.flushStop != nil {
+		return
+	}
+
+	l.flushStop = make(chan struct{})
+	l.flushDone = make(chan struct{})
+	go l.flushRun(l.FlushInterval, l.flushStop, l.flushDone)
+}
+
+// flushRun flushes the buffer every d until stop is closed. A failed flush
+// is reported to ErrorHandler, the buffer keeps it for the next Write.
+func (l *Logrotate) flushRun(d time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	t := time.NewTicker(d)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			l.mu.Lock()
+			l.reportError(l.flush())
+			l.unlock()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// stopFlusher stops the flushing goroutine and waits until it exits. It
+// must be called without mu held.
+func (l *Logrotate) stopFlusher() {
+	l.mu.Lock()
+	if l.flushStop != nil {
+		close(l.flushStop)
+		l.flushStop = nil
+	}
+	done := l.flushDone
+	l.flushDone = nil
+	l.mu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
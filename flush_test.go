@@ -0,0 +1,28 @@
+package logrotate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFlushInterval(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithBufferSize(4096))
+	l.FlushInterval = 10 * time.Millisecond
+
+	write(t, l, "one\n")
+	deadline := time.Now().Add(5 * time.Second)
+	for readFile(t, filename) != "one\n" {
+		if time.Now().After(deadline) {
+			t.Fatalf("file = %q, not flushed after FlushInterval", readFile(t, filename))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Close flushes what the ticker did not yet.
+	write(t, l, "two\n")
+	closeLog(t, l)
+	if got := readFile(t, filename); got != "one\ntwo\n" {
+		t.Fatalf("file after Close = %q", got)
+	}
+}
@@ -0,0 +1,74 @@
+package logrotate
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// freeCheckInterval is the minimum time between two checks of the free
+// space for MinFreeDisk.
+const freeCheckInterval = time.Second
+
+// lowDisk reports whether the file system of Filename has less than
+// MinFreeDisk bytes free. It checks at most once per freeCheckInterval and
+// reports false if the free space is unknown.
+func (l *Logrotate) lowDisk() bool {
+	if l.MinFreeDisk <= 0 {
+		return false
+	}
+
+	now := l.timeNow()
+	if !l.freeChecked.IsZero() && now.Sub(l.freeChecked) < freeCheckInterval {
+		return false
+	}
+	l.freeChecked = now
+
+	free, err := l.filesystem().FreeSpace(filepath.Dir(l.Filename))
+	if err != nil {
+		l.reportError(err)
+		return false
+	}
+	return free >= 0 && free < l.MinFreeDisk
+}
+
+// reclaimDisk rotates the current file and removes the oldest backups
+// until MinFreeDisk bytes are free in backupDir. The newest backup and the
+// KeepMinimum newest are kept, and with FailOnExceed none is removed.
+func (l *Logrotate) reclaimDisk() error {
+	if l.size > 0 {
+		err := l.rotateForWrite(RotateDiskSpace)
+		if err != nil {
+			return err
+		}
+	}
+	if l.RetentionPolicy == FailOnExceed {
+		return nil
+	}
+
+	// the mill must not compress a backup while it is removed.
+	l.millPending.Wait()
+
+	list, err := l.backups()
+	if err != nil {
+		return err
+	}
+
+	least := l.KeepMinimum
+	if least < 1 {
+		least = 1
+	}
+
+	dir := l.backupDir()
+	for i := len(list) - 1; i >= least; i-- {
+		free, err := l.filesystem().FreeSpace(dir)
+		if err != nil || free >= l.MinFreeDisk {
+			return err
+		}
+
+		if err := l.removeBackup(list[i].path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
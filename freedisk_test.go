@@ -0,0 +1,116 @@
+package logrotate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// freeFS is the os fileSystem reporting the free space of dir with free.
+type freeFS struct {
+	osFS
+	free func(dir string) int64
+}
+
+func (fs freeFS) FreeSpace(dir string) (int64, error) {
+	return fs.free(dir), nil
+}
+
+func TestMinFreeDisk(t *testing.T) {
+	filename := testFilename(t)
+	dir := filepath.Dir(filename)
+	clock := newTestClock()
+	var names []string
+	for h := 4; h >= 1; h-- {
+		names = append(names, writeBackup(t, filename, clock.Now().Add(-time.Duration(h)*time.Hour), "old\n"))
+	}
+
+	// each file takes 100 bytes of a disk with 1000, 400 must be free.
+	var other int64
+	l := newLogrotate(filename, WithClock(clock))
+	l.MinFreeDisk = 400
+	l.fs = freeFS{free: func(string) int64 {
+		return 1000 - 100*int64(len(backupFiles(t, dir))+1) - other
+	}}
+	defer closeLog(t, l)
+
+	write(t, l, "new\n")
+	if got := backupFiles(t, dir); len(got) != 4 {
+		t.Fatalf("backups = %q, want all with enough space", got)
+	}
+
+	// other files take 200 bytes, which is seen by the next check.
+	other = 200
+	write(t, l, "more\n")
+	if got := backupFiles(t, dir); len(got) != 4 {
+		t.Fatalf("backups = %q, want all until the next check", got)
+	}
+	clock.Advance(2 * freeCheckInterval)
+	write(t, l, "last\n")
+
+	// the rotation made 5 backups, the 2 oldest went for 400 free bytes.
+	got := backupFiles(t, dir)
+	if len(got) != 3 || got[0] != names[2] || got[1] != names[3] {
+		t.Fatalf("backups = %q, want the 3 newest", got)
+	}
+	if got := readFile(t, filename); got != "last\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
+
+func TestMinFreeDiskKeeps(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		keepMinimum int
+		policy      RetentionPolicy
+		archive     bool
+		want        int
+	}{
+		{"newest", 0, DropOldest, false, 1},
+		{"KeepMinimum", 3, DropOldest, false, 3},
+		{"FailOnExceed", 0, FailOnExceed, false, 5},
+		{"ArchiveDir", 0, DropOldest, true, 5},
+	} {
+		filename := testFilename(t)
+		dir := filepath.Dir(filename)
+		clock := newTestClock()
+		l := newLogrotate(filename, WithClock(clock))
+		l.MinFreeDisk = 400
+		l.KeepMinimum = tt.keepMinimum
+		l.RetentionPolicy = tt.policy
+		l.MaxBackups = 10
+		if tt.archive {
+			// the backups are on another file system with space.
+			l.ArchiveDir = "archive"
+			dir = filepath.Join(dir, "archive")
+			if err := os.Mkdir(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		l.fs = freeFS{free: func(d string) int64 {
+			if tt.archive && d == dir {
+				return 1 << 30
+			}
+			return 0
+		}}
+
+		for h := 4; h >= 1; h-- {
+			writeBackup(t, filepath.Join(dir, "app.log"), clock.Now().Add(-time.Duration(h)*time.Hour), "old\n")
+		}
+		write(t, l, "first\n")
+		clock.Advance(2 * freeCheckInterval)
+		write(t, l, "new\n")
+		closeLog(t, l)
+
+		// the rotation made 5 backups.
+		got := backupFiles(t, dir)
+		if len(got) != tt.want {
+			t.Errorf("%s: backups = %q, want %d", tt.name, got, tt.want)
+			continue
+		}
+		if newest := got[len(got)-1]; readFile(t, filepath.Join(dir, newest)) != "first\n" {
+			t.Errorf("%s: newest backup %q is not the rotated file", tt.name, newest)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin
+
+package logrotate
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// freeSpace returns the bytes available to unprivileged users on the file
+// system of dir.
+func freeSpace(dir string) (int64, error) {
+	var st syscall.Statfs_t
+	err := syscall.Statfs(dir, &st)
+	if err != nil {
+		return 0, fmt.Errorf("logrotate: statfs %q: %w", dir, err)
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
@@ -0,0 +1,61 @@
+package logrotate
+
+import (
+	"io"
+	"os"
+)
+
+// fileSystem is the part of the os package used to write and rotate files,
+// so tests can inject failures.
+type fileSystem interface {
+	OpenFile(name string, flag int, perm os.FileMode) (file, error)
+	Rename(oldpath, newpath string) error
+	Stat(name string) (os.FileInfo, error)
+	MkdirAll(path string, perm os.FileMode) error
+	Remove(name string) error
+
+	// FreeSpace returns the bytes available in the directory, or -1 if
+	// it is unknown.
+	FreeSpace(dir string) (int64, error)
+}
+
+// file is the part of *os.File used for the current file.
+type file interface {
+	io.Writer
+	io.ReaderAt
+	io.StringWriter
+	io.Closer
+	Name() string
+	Stat() (os.FileInfo, error)
+	Sync() error
+	Chown(uid, gid int) error
+	Chmod(mode os.FileMode) error
+	Truncate(size int64) error
+	Seek(offset int64, whence int) (int64, error)
+}
+
+// osFS is the fileSystem of the os package.
+type osFS struct{}
+
+func (osFS) OpenFile(name string, flag int, perm os.FileMode) (file, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		// avoid a non-nil interface holding a nil *os.File.
+		return nil, err
+	}
+	return f, nil
+}
+
+func (osFS) Rename(oldpath, newpath string) error         { return os.Rename(oldpath, newpath) }
+func (osFS) Stat(name string) (os.FileInfo, error)        { return os.Stat(name) }
+func (osFS) MkdirAll(path string, perm os.FileMode) error { return os.MkdirAll(path, perm) }
+func (osFS) Remove(name string) error                     { return os.Remove(name) }
+func (osFS) FreeSpace(dir string) (int64, error)          { return freeSpace(dir) }
+
+// filesystem returns the fileSystem of l, the os package by default.
+func (l *Logrotate) filesystem() fileSystem {
+	if l.fs == nil {
+		return osFS{}
+	}
+	return l.fs
+}
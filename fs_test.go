@@ -0,0 +1,137 @@
+package logrotate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenameErrorFromWrite(t *testing.T) {
+	fails := 1
+	l := newLogrotate(testFilename(t), WithMaxSizeBytes(10))
+	l.fs = failRenameFS{n: &fails}
+	defer closeLog(t, l)
+
+	write(t, l, "123456789\n")
+	_, err := l.Write([]byte("abc\n"))
+	if !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("Write = %v, want the rename error", err)
+	}
+	var lerr *os.LinkError
+	if !errors.As(err, &lerr) || !strings.HasPrefix(err.Error(), "logrotate: ") {
+		t.Fatalf("Write = %v, want a wrapped *os.LinkError", err)
+	}
+}
+
+// failRemoveFS is the os fileSystem failing every remove.
+type failRemoveFS struct {
+	osFS
+}
+
+func (failRemoveFS) Remove(name string) error {
+	return &os.PathError{Op: "remove", Path: name, Err: os.ErrPermission}
+}
+
+func TestRemoveErrorFromRotate(t *testing.T) {
+	clock := newTestClock()
+	l := newLogrotate(testFilename(t), WithMaxBackups(1), WithClock(clock))
+	l.fs = failRemoveFS{}
+	defer closeLog(t, l)
+
+	write(t, l, "one\n")
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	write(t, l, "two\n")
+	err := l.Rotate()
+	if !errors.Is(err, os.ErrPermission) || !strings.HasPrefix(err.Error(), "logrotate: remove ") {
+		t.Fatalf("Rotate = %v, want the wrapped remove error", err)
+	}
+
+	// the rotation itself happened.
+	if got := l.Size(); got != 0 {
+		t.Fatalf("Size() = %d, want 0", got)
+	}
+}
+
+// shortFS is the os fileSystem whose files write only half of the next
+// n writes, failing with errDiskFull.
+type shortFS struct {
+	osFS
+	n *int
+}
+
+var errDiskFull = errors.New("disk full")
+
+func (fs shortFS) OpenFile(name string, flag int, perm os.FileMode) (file, error) {
+	f, err := fs.osFS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return shortFile{f, fs.n}, nil
+}
+
+type shortFile struct {
+	file
+	n *int
+}
+
+func (f shortFile) Write(p []byte) (int, error) {
+	if *f.n > 0 {
+		*f.n--
+		n, _ := f.file.Write(p[:len(p)/2])
+		return n, errDiskFull
+	}
+	return f.file.Write(p)
+}
+
+func TestShortWrite(t *testing.T) {
+	filename := testFilename(t)
+	fails := 1
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(newTestClock()))
+	l.fs = shortFS{n: &fails}
+	defer closeLog(t, l)
+
+	n, err := l.Write([]byte("12345678"))
+	if n != 4 || !errors.Is(err, errDiskFull) {
+		t.Fatalf("Write = %d, %v, want 4, %v", n, err, errDiskFull)
+	}
+
+	// the size counts the 4 bytes on disk, so 6 more fit.
+	write(t, l, "abcdef")
+	if got := readFile(t, filename); got != "1234abcdef" {
+		t.Fatalf("file = %q", got)
+	}
+	write(t, l, "x")
+	if got := backupContents(t, filepath.Dir(filename)); len(got) != 1 || got[0] != "1234abcdef" {
+		t.Fatalf("backups = %q", got)
+	}
+}
+
+func TestBestEffort(t *testing.T) {
+	filename := testFilename(t)
+	fails := 1
+	var handled []error
+	l := newLogrotate(filename)
+	l.fs = shortFS{n: &fails}
+	l.BestEffort = true
+	l.ErrorHandler = func(err error) { handled = append(handled, err) }
+	defer closeLog(t, l)
+
+	n, err := l.Write([]byte("12345678"))
+	if n != 8 || err != nil {
+		t.Fatalf("Write = %d, %v, want 8, nil", n, err)
+	}
+	if len(handled) != 1 || !errors.Is(handled[0], errDiskFull) {
+		t.Fatalf("ErrorHandler got %v, want %v", handled, errDiskFull)
+	}
+
+	write(t, l, "abc\n")
+	if got := readFile(t, filename); got != "1234abc\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
@@ -0,0 +1,103 @@
+//go:build !windows && !plan9
+
+package logrotate
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fullFS is the os fileSystem whose files fail the next n writes with
+// ENOSPC, like a full disk.
+type fullFS struct {
+	osFS
+	n *int
+}
+
+func (fs fullFS) OpenFile(name string, flag int, perm os.FileMode) (file, error) {
+	f, err := fs.osFS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return fullFile{f, fs.n}, nil
+}
+
+type fullFile struct {
+	file
+	n *int
+}
+
+func (f fullFile) Write(p []byte) (int, error) {
+	if *f.n > 0 {
+		*f.n--
+		return 0, &os.PathError{Op: "write", Path: f.Name(), Err: syscall.ENOSPC}
+	}
+	return f.file.Write(p)
+}
+
+func TestRecoverFromFullDisk(t *testing.T) {
+	filename := testFilename(t)
+	fails := 1
+	l := newLogrotate(filename)
+	l.fs = fullFS{n: &fails}
+	defer closeLog(t, l)
+
+	if _, err := l.Write([]byte("lost\n")); !errors.Is(err, syscall.ENOSPC) {
+		t.Fatalf("Write = %v, want ENOSPC", err)
+	}
+	write(t, l, "recovered\n")
+	if got := readFile(t, filename); got != "recovered\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
+
+// busyFS is the os fileSystem failing the next n renames with EBUSY.
+type busyFS struct {
+	osFS
+	n *int
+}
+
+func (fs busyFS) Rename(oldpath, newpath string) error {
+	if *fs.n > 0 {
+		*fs.n--
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EBUSY}
+	}
+	return fs.osFS.Rename(oldpath, newpath)
+}
+
+func TestRotateRetries(t *testing.T) {
+	for _, tt := range []struct {
+		retries int
+		ok      bool
+	}{
+		{1, false},
+		{2, true},
+	} {
+		fails := 2
+		clock := newTestClock()
+		start := clock.Now()
+		l := newLogrotate(testFilename(t), WithClock(clock))
+		l.RotateRetries = tt.retries
+		l.RotateRetryBackoff = time.Second
+		l.fs = busyFS{n: &fails}
+		write(t, l, "x\n")
+
+		err := l.Rotate()
+		closeLog(t, l)
+		if tt.ok != (err == nil) || !tt.ok && !errors.Is(err, syscall.EBUSY) {
+			t.Fatalf("%d retries: Rotate = %v", tt.retries, err)
+		}
+
+		// the backoff doubles, by the Clock.
+		want := time.Second
+		if tt.ok {
+			want = 3 * time.Second
+		}
+		if got := clock.Now().Sub(start); got != want {
+			t.Fatalf("%d retries: waited %v, want %v", tt.retries, got, want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package logrotate
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Decompressor is implemented by a Compressor which can also read what it
+// wrote, for OpenFullReader.
+type Decompressor interface {
+	// NewReader returns a reader decompressing src.
+	NewReader(src io.Reader) (io.ReadCloser, error)
+}
+
+func (gzipCompressor) NewReader(src io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(src)
+}
+
+// OpenFullReader returns a reader of all backups from oldest to newest,
+// followed by the current file, with compressed backups decompressed. The
+// backups and the size of the current file are taken when it is called,
+// backups removed while reading are skipped. Backups of a Compressor which
+// is no Decompressor fail the read. On Windows the open current file can
+// not be rotated until the reader is closed.
+func (l *Logrotate) OpenFullReader() (io.ReadCloser, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := l.flush()
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := l.backups()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &fullReader{ext: l.compressExt()}
+	if d, ok := l.compressor().(Decompressor); ok {
+		r.dec = d
+	}
+	for i := len(list) - 1; i >= 0; i-- {
+		r.paths = append(r.paths, list[i].path)
+	}
+
+	f, err := os.Open(l.Filename)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("logrotate: open %q: %w", l.Filename, err)
+	}
+	if err == nil {
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("logrotate: stat %q: %w", l.Filename, err)
+		}
+		// opened now, so a rotation while reading does not change it.
+		r.active = f
+		r.activeSize = info.Size()
+	}
+
+	return r, nil
+}
+
+// fullReader is the reader of OpenFullReader.
+type fullReader struct {
+	paths      []string // of the backups still to read, uncompressed.
+	ext        string
+	dec        Decompressor
+	active     *os.File
+	activeSize int64
+
+	cur     io.Reader
+	closers []io.Closer // of cur.
+}
+
+func (r *fullReader) Read(p []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			err := r.next()
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		n, err := r.cur.Read(p)
+		if err == io.EOF {
+			r.closeCurrent()
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// next opens the next part, the current file after the backups. It returns
+// io.EOF at the end.
+func (r *fullReader) next() error {
+	for len(r.paths) > 0 {
+		path := r.paths[0]
+		r.paths = r.paths[1:]
+
+		f, err := os.Open(path)
+		if err == nil {
+			r.cur, r.closers = f, []io.Closer{f}
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("logrotate: open %q: %w", path, err)
+		}
+
+		f, err = os.Open(path + r.ext)
+		if os.IsNotExist(err) {
+			// removed by the retention meanwhile.
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("logrotate: open %q: %w", path+r.ext, err)
+		}
+
+		if r.dec == nil {
+			f.Close()
+			return fmt.Errorf("logrotate: decompress %q: no Decompressor", path+r.ext)
+		}
+		d, err := r.dec.NewReader(f)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("logrotate: decompress %q: %w", path+r.ext, err)
+		}
+		r.cur, r.closers = d, []io.Closer{d, f}
+		return nil
+	}
+
+	if r.active != nil {
+		r.cur = io.LimitReader(r.active, r.activeSize)
+		r.closers = []io.Closer{r.active}
+		r.active = nil
+		return nil
+	}
+	return io.EOF
+}
+
+// closeCurrent closes the part being read.
+func (r *fullReader) closeCurrent() {
+	for _, c := range r.closers {
+		c.Close()
+	}
+	r.cur, r.closers = nil, nil
+}
+
+// Close closes the open files.
+func (r *fullReader) Close() error {
+	r.closeCurrent()
+	r.paths = nil
+	if r.active != nil {
+		r.active.Close()
+		r.active = nil
+	}
+	return nil
+}
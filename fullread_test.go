@@ -0,0 +1,85 @@
+package logrotate
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenFullReader(t *testing.T) {
+	filename := testFilename(t)
+	dir := filepath.Dir(filename)
+	clock := newTestClock()
+	l := newLogrotate(filename, WithCompress(true), WithClock(clock))
+	l.CompressMinSize = 20
+	defer closeLog(t, l)
+
+	// the short files stay plain, the long ones get compressed.
+	var all string
+	for i, s := range []string{"short 1\n", strings.Repeat("long 2\n", 5), "short 3\n", strings.Repeat("long 4\n", 5)} {
+		write(t, l, s)
+		all += s
+		if err := l.Rotate(); err != nil {
+			t.Fatalf("rotation %d: %v", i, err)
+		}
+		clock.Advance(time.Minute)
+	}
+	write(t, l, "current\n")
+	all += "current\n"
+	gz := 0
+	for _, name := range backupFiles(t, dir) {
+		if strings.HasSuffix(name, ".gz") {
+			gz++
+		}
+	}
+	if gz != 2 {
+		t.Fatalf("backups = %q, want 2 of 4 compressed", backupFiles(t, dir))
+	}
+
+	r, err := l.OpenFullReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, l, "later\n") // after the snapshot.
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != all {
+		t.Fatalf("read %q, want %q", b, all)
+	}
+}
+
+func TestOpenFullReaderPruned(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	first := writeBackup(t, filename, clock.Now().Add(-2*time.Hour), "first\n")
+	writeBackup(t, filename, clock.Now().Add(-time.Hour), "second\n")
+	l := newLogrotate(filename, WithClock(clock))
+	defer closeLog(t, l)
+	write(t, l, "current\n")
+
+	r, err := l.OpenFullReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// a backup removed before it is read is skipped.
+	if err := os.Remove(filepath.Join(filepath.Dir(filename), first)); err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "second\ncurrent\n" {
+		t.Fatalf("read %q", b)
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/TermiusOne/logrotate
+
+go 1.21
@@ -0,0 +1,91 @@
+package logrotate
+
+import (
+	"errors"
+	"time"
+)
+
+// RotationGroup rotates related files together, like "app.log" and
+// "app.err" of one application, so their backups have the same timestamp.
+type RotationGroup struct {
+	Members []*Logrotate
+}
+
+// NewRotationGroup returns a RotationGroup of members.
+func NewRotationGroup(members ...*Logrotate) *RotationGroup {
+	return &RotationGroup{Members: members}
+}
+
+// RotateAll rotates every member like Rotate, naming all backups with the
+// time of the Clock of the first member. When that name is taken for any
+// member, all get the same ".N" suffix, so the names still match. Names of
+// IndexMode and BackupNameFunc are left as they are.
+//
+// An invalid compression setup of any member fails RotateAll before a
+// member is rotated. Otherwise a member which fails does not stop the
+// others, and the rotations done are not undone: the errors of all are
+// returned joined.
+func (g *RotationGroup) RotateAll() error {
+	if len(g.Members) == 0 {
+		return nil
+	}
+
+	t := g.Members[0].now()
+
+	seq := 0
+	for _, l := range g.Members {
+		n, err := l.checkRotateAt(t)
+		if err != nil {
+			return err
+		}
+		if n > seq {
+			seq = n
+		}
+	}
+
+	var errs []error
+	for _, l := range g.Members {
+		errs = append(errs, l.rotateAt(t, seq))
+	}
+	return errors.Join(errs...)
+}
+
+// now returns the time by the Clock.
+func (l *Logrotate) now() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.timeNow()
+}
+
+// checkRotateAt returns the N of the ".N" suffix a backup named by the
+// time t needs, or why l cannot rotate.
+func (l *Logrotate) checkRotateAt(t time.Time) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := l.checkCompress()
+	if err != nil {
+		return 0, err
+	}
+	if l.BackupNameFunc != nil || l.BackupMode == IndexMode {
+		return 0, nil
+	}
+
+	l.rotateTime = t
+	defer func() { l.rotateTime = time.Time{} }()
+
+	_, n := l.backupNameSeq()
+	return n, nil
+}
+
+// rotateAt is Rotate with backups named by the time t and at least the
+// ".N" suffix seq.
+func (l *Logrotate) rotateAt(t time.Time, seq int) error {
+	l.mu.Lock()
+	defer l.unlock()
+
+	l.rotateTime, l.rotateSeq = t, seq
+	defer func() { l.rotateTime, l.rotateSeq = time.Time{}, 0 }()
+
+	return l.rotate()
+}
@@ -0,0 +1,98 @@
+package logrotate
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// groupBackups returns the suffixes after the base name of the backups of
+// the files names.
+func groupBackups(t *testing.T, names ...string) [][]string {
+	t.Helper()
+	var got [][]string
+	for _, name := range names {
+		var suffixes []string
+		for _, b := range backupFiles(t, filepath.Dir(name)) {
+			if s, ok := strings.CutPrefix(b, filepath.Base(name)); ok && s != "" {
+				suffixes = append(suffixes, s)
+			}
+		}
+		got = append(got, suffixes)
+	}
+	return got
+}
+
+func TestRotateAll(t *testing.T) {
+	dir := t.TempDir()
+	logName, errName := filepath.Join(dir, "app.log"), filepath.Join(dir, "app.err")
+	clock := newTestClock()
+	log := newLogrotate(logName, WithClock(clock))
+	errLog := newLogrotate(errName, WithClock(clock))
+	g := NewRotationGroup(log, errLog)
+	write(t, log, "out\n")
+	write(t, errLog, "err\n")
+
+	if err := g.RotateAll(); err != nil {
+		t.Fatal(err)
+	}
+	stamp := "." + clock.Now().Format(backupTimeFormat)
+	want := [][]string{{stamp}, {stamp}}
+	if got := groupBackups(t, logName, errName); !reflect.DeepEqual(got, want) {
+		t.Fatalf("backups = %q, want %q", got, want)
+	}
+
+	// the name is taken for app.log only, both get the same suffix.
+	if err := errLog.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(errName + stamp + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	write(t, log, "out\n")
+	write(t, errLog, "err\n")
+	if err := log.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.RotateAll(); err != nil {
+		t.Fatal(err)
+	}
+	want = [][]string{{stamp, stamp + ".1", stamp + ".2"}, {stamp, stamp + ".2"}}
+	if got := groupBackups(t, logName, errName); !reflect.DeepEqual(got, want) {
+		t.Fatalf("backups = %q, want %q", got, want)
+	}
+	closeLog(t, log)
+	closeLog(t, errLog)
+}
+
+func TestRotateAllChecksFirst(t *testing.T) {
+	dir := t.TempDir()
+	logName, errName := filepath.Join(dir, "app.log"), filepath.Join(dir, "app.err")
+	clock := newTestClock()
+	log := newLogrotate(logName, WithClock(clock))
+	errLog := newLogrotate(errName, WithClock(clock), WithCompress(true))
+	errLog.CompressCommand = []string{"zstd"}
+	defer closeLog(t, log)
+	defer closeLog(t, errLog)
+	write(t, log, "out\n")
+	write(t, errLog, "err\n")
+
+	if err := NewRotationGroup(log, errLog).RotateAll(); err == nil || !strings.Contains(err.Error(), "no extension") {
+		t.Fatalf("RotateAll = %v, want the compress setup error", err)
+	}
+	if got := groupBackups(t, logName, errName); len(got[0])+len(got[1]) != 0 {
+		t.Fatalf("backups = %q, want none", got)
+	}
+
+	// the group is not left at the time of the failed call.
+	clock.Advance(time.Minute)
+	if err := log.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := groupBackups(t, logName)[0], []string{"." + clock.Now().Format(backupTimeFormat)}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("backups = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package logrotate
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+)
+
+// hashSuffix is appended to a backup name for its HashBackups sidecar.
+const hashSuffix = ".sha256"
+
+// finishBackup compresses the new backup name with Compress and then writes
+// its hash with HashBackups.
+func (l *Logrotate) finishBackup(name string) error {
+	if l.Compress {
+		err := l.compress(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	if l.HashBackups {
+		return l.hashBackup(name)
+	}
+	return nil
+}
+
+// hashBackup writes the hex SHA-256 of the backup name, compressed or not,
+// to a sidecar file. It holds no file name, so it stays valid when backups
+// are shifted in IndexMode.
+func (l *Logrotate) hashBackup(name string) error {
+	path := name
+	if !exists(path) {
+		path += l.compressExt()
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("logrotate: hash %q: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("logrotate: hash %q: %w", path, err)
+	}
+
+	sum := fmt.Sprintf("%x\n", h.Sum(nil))
+	err = os.WriteFile(path+hashSuffix, []byte(sum), l.fileMode())
+	if err != nil {
+		return fmt.Errorf("logrotate: hash %q: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package logrotate
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHashBackups(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		filename := testFilename(t)
+		dir := filepath.Dir(filename)
+		clock := newTestClock()
+		l := newLogrotate(filename, WithMaxBackups(1), WithCompress(compress), WithClock(clock))
+		l.HashBackups = true
+
+		write(t, l, "one\n")
+		if err := l.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		first := dirNames(t, dir)
+		if len(first) != 3 {
+			t.Fatalf("compress %t: files = %q, want the file, a backup and its hash", compress, first)
+		}
+		backup := filepath.Join(dir, first[1])
+		data, err := os.ReadFile(backup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := readFile(t, backup+hashSuffix), fmt.Sprintf("%x\n", sha256.Sum256(data)); got != want {
+			t.Fatalf("compress %t: hash = %q, want %q", compress, got, want)
+		}
+
+		// pruning the backup removes its hash too.
+		clock.Advance(time.Minute)
+		write(t, l, "two\n")
+		if err := l.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		closeLog(t, l)
+		for _, name := range []string{backup, backup + hashSuffix} {
+			if _, err := os.Stat(name); !os.IsNotExist(err) {
+				t.Errorf("compress %t: %s left after pruning: %v", compress, filepath.Base(name), err)
+			}
+		}
+		if names := dirNames(t, dir); len(names) != 3 {
+			t.Errorf("compress %t: files = %q, want the file, a backup and its hash", compress, names)
+		}
+	}
+}
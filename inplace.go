@@ -0,0 +1,34 @@
+package logrotate
+
+import (
+	"fmt"
+	"os"
+)
+
+// compressInPlace reports whether rotations compress Filename straight into
+// the backup with CompressInPlace.
+func (l *Logrotate) compressInPlace() bool {
+	return l.CompressInPlace && l.Compress && l.MoveFunc == nil && l.PostRotate == nil && !l.FastRotate
+}
+
+// moveCompressed compresses Filename into the backup name plus the
+// compression extension and removes it, instead of moving it to name to be
+// compressed later. The data goes to a ".tmp" file renamed once synced, so
+// an interruption leaves Filename and no partial backup. Files smaller than
+// CompressMinSize are moved as usual.
+func (l *Logrotate) moveCompressed(name string) error {
+	if l.CompressMinSize > 0 {
+		info, err := os.Stat(l.Filename)
+		if err == nil && info.Size() < l.CompressMinSize {
+			return l.moveFile(l.Filename, name)
+		}
+	}
+
+	ext := l.compressExt()
+	err := compressFile(l.compressor(), l.Filename, name+ext, name+ext+tempSuffix, l.compressBufferSize())
+	if err != nil {
+		return fmt.Errorf("logrotate: compress %q: %w", l.Filename, err)
+	}
+	l.diagf("compress %q to %q", l.Filename, name+ext)
+	return nil
+}
@@ -0,0 +1,124 @@
+package logrotate
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// probeCompressor is gzip listing the directory dir when the compression
+// is complete, and failing it with fail.
+type probeCompressor struct {
+	dir  string
+	seen *[]string
+	fail error
+}
+
+func (probeCompressor) Extension() string { return ".gz" }
+
+func (c probeCompressor) NewWriter(dst io.Writer) (io.WriteCloser, error) {
+	return probeWriter{gzip.NewWriter(dst), c}, nil
+}
+
+type probeWriter struct {
+	*gzip.Writer
+	c probeCompressor
+}
+
+func (w probeWriter) Close() error {
+	entries, err := os.ReadDir(w.c.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		*w.c.seen = append(*w.c.seen, e.Name())
+	}
+	if w.c.fail != nil {
+		return w.c.fail
+	}
+	return w.Writer.Close()
+}
+
+func TestCompressInPlace(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		filename := testFilename(t)
+		dir := filepath.Dir(filename)
+		var seen []string
+		l := newLogrotate(filename, WithMaxSizeBytes(100), WithCompress(true), WithClock(newTestClock()))
+		l.Compressor = probeCompressor{dir: dir, seen: &seen}
+		l.CompressInPlace = true
+		l.AsyncCleanup = async
+		data := strings.Repeat("x", 98) + "\n"
+		write(t, l, data)
+		write(t, l, "next\n")
+		closeLog(t, l)
+
+		// while compressing only the file and the partial backup exist, no
+		// uncompressed copy.
+		name := "app.log.2024-03-01T10-00-00.gz"
+		if want := []string{"app.log", name + tempSuffix}; !reflect.DeepEqual(seen, want) {
+			t.Fatalf("async %t: seen %q while compressing, want %q", async, seen, want)
+		}
+		if got := backupFiles(t, dir); !reflect.DeepEqual(got, []string{name}) {
+			t.Fatalf("async %t: backups = %q", async, got)
+		}
+		if got := gunzip(t, filepath.Join(dir, name)); got != data {
+			t.Fatalf("async %t: backup = %q", async, got)
+		}
+		if got := readFile(t, filename); got != "next\n" {
+			t.Fatalf("async %t: file = %q", async, got)
+		}
+	}
+}
+
+func TestCompressInPlaceFastRotate(t *testing.T) {
+	filename := testFilename(t)
+	dir := filepath.Dir(filename)
+	var seen []string
+	l := newLogrotate(filename, WithMaxSizeBytes(100), WithCompress(true), WithClock(newTestClock()))
+	l.Compressor = probeCompressor{dir: dir, seen: &seen}
+	l.CompressInPlace = true
+	l.FastRotate = true
+	data := strings.Repeat("x", 98) + "\n"
+	write(t, l, data)
+	write(t, l, "next\n")
+	closeLog(t, l)
+
+	// FastRotate moves the file first, so the plain backup is compressed.
+	name := "app.log.2024-03-01T10-00-00"
+	if want := []string{"app.log", name, name + ".gz"}; !reflect.DeepEqual(seen, want) {
+		t.Fatalf("seen %q while compressing, want %q", seen, want)
+	}
+	if got := gunzip(t, filepath.Join(dir, name+".gz")); got != data {
+		t.Fatalf("backup = %q", got)
+	}
+}
+
+func TestCompressInPlaceFails(t *testing.T) {
+	filename := testFilename(t)
+	dir := filepath.Dir(filename)
+	errBroken := errors.New("broken compressor")
+	var seen []string
+	l := newLogrotate(filename, WithMaxSizeBytes(100), WithCompress(true), WithClock(newTestClock()))
+	l.Compressor = probeCompressor{dir: dir, seen: &seen, fail: errBroken}
+	l.CompressInPlace = true
+	write(t, l, "kept\n")
+
+	if err := l.Rotate(); !errors.Is(err, errBroken) {
+		t.Fatalf("Rotate = %v, want the compress error", err)
+	}
+	closeLog(t, l)
+
+	// the file keeps its data and no partial backup is left.
+	if got := backupFiles(t, dir); len(got) != 0 {
+		t.Fatalf("backups = %q, want none", got)
+	}
+	if got := readFile(t, filename); !strings.HasPrefix(got, "kept\n") {
+		t.Fatalf("file = %q", got)
+	}
+}
@@ -1,127 +1,1993 @@
 package logrotate
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Size units. Sizes given in megabytes, like the size of NewLogrotate, are
+// binary megabytes. Use DecimalMegabyte with byte sizes for decimal ones.
 const (
-	defaultSize int64 = 1           // in Mbyte of log file.
-	megabyte    int64 = 1024 * 1024 // base Mbyte.
+	Megabyte        int64 = 1024 * 1024 // binary megabyte, MiB.
+	DecimalMegabyte int64 = 1000 * 1000 // decimal megabyte, MB.
+)
+
+const (
+	defaultSize int64 = 10 // in Mbyte of log file.
+
+	readFromSize = 128 * 1024 // buffer of ReadFrom.
+
+	defaultFileMode os.FileMode = 0666 // of log files.
+	defaultDirMode  os.FileMode = 0755 // of created directories.
+
+	backupTimeFormat = "2006-01-02T15-04-05" // suffix of backup files.
 )
 
 // NewLogrotate return logrotate struct with name and max size (Mbyte) of file.
+// A size below 1 uses the default of 10 Mbyte.
 func NewLogrotate(filename string, size int64) io.WriteCloser {
-	if size < defaultSize {
-		size = defaultSize
-	}
+	return NewLogrotateT(filename, size)
+}
 
-	return &Logrotate{
-		Filename: filename,
-		MaxSize:  size * megabyte,
-	}
+// NewLogrotateT is like NewLogrotate but returns the *Logrotate, to call
+// methods like Rotate or Size without a type assertion.
+func NewLogrotateT(filename string, size int64) *Logrotate {
+	return newLogrotate(filename, WithMaxSize(size))
 }
 
+// StartupMode selects how an existing file is treated on the first open.
+type StartupMode int
+
+const (
+	// AppendMode appends to the existing file.
+	AppendMode StartupMode = iota
+
+	// TruncateMode truncates the existing file.
+	TruncateMode
+
+	// RotateExistingMode moves a non-empty existing file to a backup.
+	RotateExistingMode
+)
+
 // Logrotate is an io.WriteCloser that writes to the specified filename.
+// It is safe for concurrent use, rotation only happens between writes, so the
+// data of a single Write is never split between two files or lost. Data is
+// only appended, so Logrotate deliberately does not implement io.WriterAt.
 //
 // Filename is the file to write logs to. Backup log files will be retained
 // in the same directory.
 //
-// MaxSize is the maximum size in megabytes of the log file before it gets
-// rotated. It defaults to 10 megabytes.
+// MaxSize is the maximum size in bytes of the log file before it gets
+// rotated. Zero or negative means the default of 10 megabytes. A file may
+// reach exactly MaxSize, the write after that goes to a new file. It only
+// exceeds MaxSize when a single write into an empty file is larger.
+// DisableSizeRotation lets the file grow without limit, other rotations
+// still happen.
+//
+// SlidingWindow keeps a file reaching MaxSize instead of rotating it, and
+// drops its older half: the file is replaced by a copy of the newest
+// MaxSize/2 bytes, starting after a RecordDelimiter, behind the header. No
+// backup is made, and each slide costs a copy of half the file.
+//
+// MaxBackups is the maximum number of backup files to retain, the oldest
+// are removed after each rotation. Zero means all backups are kept.
+//
+// MaxAge is the maximum age of backup files to retain, based on the
+// timestamp in their name. Zero means backups are not removed by age.
+// KeepMinimum newest backups are always kept, even if they are older than
+// MaxAge, beyond MaxBackups or over MaxTotalSize, so a long quiet period
+// does not remove every backup.
+//
+// MaxTotalSize is the maximum size in bytes of all backup files together,
+// the active file is not counted. The oldest backups are removed after each
+// rotation until the rest fits. Zero means no limit. The newest backup, and
+// the KeepMinimum newest, are kept even if they alone are larger, and
+// ErrOverTotalSize is reported to ErrorHandler. All retention limits apply
+// together: a backup is removed when it is beyond MaxBackups or older than
+// MaxAge, and of the rest the oldest are removed until MaxTotalSize fits.
+// Backups with the same time are ordered by name, so the same backups give
+// the same result.
+//
+// RetentionPolicy selects DropOldest (default), which removes the backups
+// beyond MaxBackups, or FailOnExceed, which never removes a backup for
+// MaxBackups: rotations fail with ErrRetentionExceeded once MaxBackups
+// backups exist, and the file keeps growing past MaxSize until an operator
+// removes some. MaxAge and MaxTotalSize still remove backups. BlockUntilFreed
+// is reserved.
+//
+// Compress determines if the rotated files should be compressed using gzip.
+// The backup is compressed before the rotating Write returns.
+//
+// CompressLevel is the gzip level from gzip.HuffmanOnly to
+// gzip.BestCompression, zero means gzip.DefaultCompression. Compressor
+// replaces gzip by another format. Backups smaller than CompressMinSize bytes
+// are not compressed. Backups are streamed to the Compressor through a
+// buffer of CompressBufferSize bytes, 32 KiB by default, and never read
+// into memory whole.
+//
+// CompressCommand, if set without Compressor, compresses backups by piping
+// them through an external command like []string{"zstd", "-q"}, which reads
+// stdin and writes stdout, and the output gets the extension CompressExt
+// like ".zst". The backup is kept when the command is missing or fails.
+//
+// RepairBackups checks the backups of earlier runs on the first open. A
+// compressed backup next to its uncompressed one was left by a compression
+// that did not finish, it is removed, and compressed again with Compress.
+// Files left by AtomicCompress are removed too, and gzip backups failing to
+// decompress are reported to ErrorHandler.
+//
+// AsyncCleanup compresses and removes old backups in background, instead of
+// during the Write that rotated the file. Close waits until it finishes.
+//
+// CompressOnClose with Compress compresses Filename itself when it is
+// closed, for jobs which end before the file is rotated. A later Write
+// starts a new Filename, and Close fails rather than replace the compressed
+// file.
+//
+// CompressExisting with Compress also compresses the uncompressed backups
+// found when Filename is opened for the first time, such as those of runs
+// without Compress.
+//
+// AtomicCompress writes each compressed backup to a ".tmp" file first and
+// renames it when complete, so the compressed name never holds a partial
+// file.
+//
+// CompressInPlace with Compress compresses the file straight into the
+// backup during the rotating Write, also with AsyncCleanup, instead of
+// moving it to an uncompressed backup first. No uncompressed backup sits
+// next to the compressed one, so less space is needed when the disk is
+// nearly full. The data is written to a ".tmp" file synced and renamed when
+// complete, and the file is removed only then, so an interrupted rotation
+// leaves the file and at most a ".tmp" file ignored by the retention. It is
+// not used with MoveFunc, PostRotate or FastRotate.
+//
+// RotationInterval rotates the file once it was opened before the start of
+// the current interval. Intervals are aligned to local midnight, so 24 hours
+// rotates daily and 1 hour at the top of every hour. Zero disables it.
+//
+// MaxOpenDuration rotates the file once it was opened that long ago, counted
+// from its creation instead of aligned to the clock. Zero disables it.
+//
+// CronSchedule rotates the file by the first Write after a time of a cron
+// line passed since the file was opened. It has the five fields minute,
+// hour, day of month, month and day of week, like "0 2 * * *" for daily at
+// 02:00 or "0 0 * * 1" for every Monday, in the location of the Clock. A
+// field is "*" or a comma list of numbers and ranges, each optionally with a
+// step like "*/15". Names and seconds are not supported.
+//
+// BackupTimeFormat is the time layout of the backup file suffix. It
+// defaults to "2006-01-02T15-04-05" which is valid on every filesystem and
+// must not contain path separators.
+//
+// OnRotate is called after each rotation with the path of the backup, before
+// it is compressed, and of the new active file. It runs after the internal
+// lock is released, so it may use the Logrotate.
+//
+// Symlink is the path of a symbolic link kept pointing at the current file,
+// for tools like tail -F. Empty disables it.
+//
+// BackupMode selects timestamped (default) or indexed backup names. In
+// IndexMode MaxAge is based on the modification time of backups.
+//
+// UTC formats backup timestamps in UTC instead of the local time zone.
+//
+// InfixTimestamp puts the timestamp before the extension of Filename, so
+// "app.log" is rotated to "app.2006-01-02T15-04-05.log". The compressed
+// extension goes after it, like "app.2006-01-02T15-04-05.log.gz", the same as
+// without InfixTimestamp where it follows the timestamp. It only applies to
+// TimestampMode and file names with an extension.
+//
+// InstanceID is appended to the timestamp of backups, like
+// "app.log.2006-01-02T15-04-05.host7", so writers sharing a directory do not
+// clash. Retention then only considers the backups with this InstanceID. It
+// only applies to TimestampMode.
+//
+// Header is written at the start of every new file and counts toward its
+// size. HeaderFunc, if set, is called for each file instead. Existing files
+// are appended without a header.
+//
+// Footer is written at the end of every file before it is rotated, and
+// before Close. FooterFunc, if set, is called for each file instead. The
+// footer may take the file past MaxSize, and a file opened again after
+// Close is appended after its footer.
+//
+// FileMode and DirMode are the permissions used to create log files and
+// missing directories, before umask. They default to 0666 and 0755. A set
+// DirMode is applied to created directories regardless of umask, including
+// os.ModeSetgid and os.ModeSticky.
+// NoCreateDir never creates directories, a missing one fails the open or
+// rotation instead.
+//
+// SetOwner changes the owner of every opened file to Uid and Gid, a negative
+// id keeps the respective one. Ownership is ignored on Windows and Plan 9.
+//
+// StrictMode sets FileMode on every opened file regardless of umask.
+//
+// BufferSize enables an in-memory buffer of that many bytes in front of the
+// file. Buffered bytes count toward the size of the file and are flushed when
+// the buffer is full, before rotation, on Sync and on Close.
+// FlushInterval also flushes it in background at least that often, to bound
+// the delay of buffered data.
+//
+// StartupMode decides what happens to an existing file when it is opened for
+// the first time: AppendMode (default) appends to it, TruncateMode empties it
+// and RotateExistingMode moves it to a backup first.
+//
+// MaxLines rotates the file before it would hold more than MaxLines records,
+// counted as RecordDelimiter, by default a newline, or as Write calls with
+// CountWrites. Records already in an existing file are not counted.
+// Whichever of MaxSize, MaxLines, RotationInterval and MaxOpenDuration is
+// reached first rotates the file.
+//
+// MinRotateInterval is the minimum time between two rotations by Write.
+// Until it passed the file keeps growing past the limits, so tiny limits do
+// not rotate it for every write. Rotate is not limited.
+//
+// RotateMode selects MoveCreateMode (default), which renames the file and
+// creates a new one, or CopyTruncateMode, which copies the file to the backup
+// and truncates it, keeping the inode for readers holding it open.
+//
+// BackupNameFunc, if set, returns the backup path for the file rotated at t
+// instead of the BackupMode name. To be found for retention the backups must
+// stay in the backup directory and start with the base name of Filename,
+// their age is the modification time.
+//
+// SyncDir syncs the directory after renaming the file and creating a new
+// one, so a rotation survives a crash. It costs an extra sync per rotation
+// and open, and does nothing on Windows.
+//
+// SyncOnRotate syncs the file before it is moved to a backup, and the
+// directories like SyncDir. Since a Write is never split, every backup then
+// holds complete writes on disk, ending with the last line written before
+// the rotation, even after a crash.
+//
+// MinFreeDisk rotates the file and removes the oldest backups when less than
+// that many bytes are free on the file system of Filename, until enough are
+// free in the directory of the backups again. The newest backup and the
+// KeepMinimum newest are kept, and FailOnExceed removes none. The free space
+// is checked at most once a second by Write, and only on Linux and macOS.
+//
+// EpochFunc, if set, returns a token like the release of the program. Write
+// rotates the file when the token differs from the one at the time the file
+// was opened, and in TimestampMode the token of the file is appended to the
+// timestamp of its backup after an "@", like
+// "app.log.2006-01-02T15-04-05@v42". Path separators and "@" in the token
+// become "_". It is called by every Write.
+//
+// RotateRetries is how often opening the file and renaming it to a backup
+// are retried after a transient error like EBUSY, as seen on network file
+// systems. The first retry waits RotateRetryBackoff, and every further one
+// twice as long, by the Clock if it is a Sleeper. On Windows and Plan 9 any
+// error is retried.
+//
+// Secondary, if set, gets a copy of all data written to the file, after
+// it was written. Its errors are only reported to ErrorHandler, so they
+// never fail a Write. With CloseSecondary, the first Close also closes it if
+// it is an io.Closer, and later writes are not copied to it.
+//
+// RejectBinary fails writes holding a NUL byte or invalid UTF-8 with
+// ErrBinaryData, and writes nothing of them.
+//
+// WriteTimeout, if set, fails a write to the file which takes longer with
+// ErrWriteTimeout, for example on a network file system during an outage.
+// The write itself cannot be cancelled, it may go on in background until
+// the operating system returns, and the file is opened again by the next
+// Write.
+//
+// SizeResyncInterval, if set, makes Write stat the open file at most that
+// often and take its size when it differs from the counted one, for example
+// after an operator truncated the file with ": > app.log". Without it such
+// a file is rotated early, while still nearly empty.
+//
+// HashBackups writes the hex SHA-256 of every backup, after compression, to
+// a sidecar file named like the backup with ".sha256" appended. The sidecar
+// is renamed and removed with its backup.
+//
+// FastRotate moves the file to the backup while it is still open and
+// closes it only after the new file is open, saving a reopen if the move
+// fails. It is ignored on Windows and Plan 9, which can not rename open
+// files.
+//
+// MoveFunc, if set, moves the current file to the backup instead of a
+// rename, for targets like object storage where copying and removing works
+// better. It must leave nothing at src on success. After a failure the file
+// is opened again and the rotation retried by a later Write.
+//
+// OpenReadWrite opens files for reading too, so ReadTail reads the open
+// file instead of opening it again.
+//
+// NewFileTruncate truncates a file found at Filename when the new file is
+// opened after a rotation, for example one created by another process
+// right after the move, so each file starts empty. Files are otherwise
+// appended to. CopyTruncateMode always truncates the file in place.
+//
+// ShouldRotate, if set, is called before each Write and WriteString, and
+// before each chunk written by ReadFrom, with the data about to be written,
+// and the file is rotated first when it returns true. It runs under the
+// lock, so it must not call back into Write or any other method that locks;
+// SizeLocked and OpenTimeLocked read the state of the current file.
+//
+// WatchActive checks before each write that Filename is still the open file,
+// and opens it again if it was removed or replaced. It costs a stat per
+// write.
+//
+// Preallocate reserves MaxSize bytes on disk for every opened file, to
+// avoid fragmentation and run out of space early. The size of the file and
+// rotation are not affected. It only works on Linux, and failures are
+// reported to ErrorHandler.
+//
+// ArchiveDir, if set, is the directory backups are moved to and searched in
+// for retention, created when missing. A relative ArchiveDir is relative to
+// the directory of Filename. Across file systems the file is copied instead
+// of renamed. Paths from BackupNameFunc are used as they are.
+//
+// BestEffort makes a failed Write only report its error and return as if
+// all data was written, so an io.MultiWriter keeps writing to other sinks.
+// The data is lost and the file is opened again by the next Write.
+//
+// ErrorHandler, if set, is called with the errors dropped by BestEffort and
+// otherwise ignored ones, like a failed Symlink update or background flush.
+// It runs after the internal lock is released. Errors of the background
+// work of AsyncCleanup are passed to it by the next call to the Logrotate,
+// or by Close, and the first one is also returned by Close or Drain.
+//
+// PostRotate, if set, is called with each new backup before it is compressed
+// and may move it, returning the new path. Backups keep their name to be
+// found for retention, and only directories used by the running Logrotate
+// are searched. It runs with the internal lock held, so it must not use the
+// Logrotate. An error is reported to ErrorHandler, the rotation still
+// succeeds and the backup stays where it was.
+//
+// The settings have JSON tags, with sizes in bytes and durations in
+// nanoseconds. Config reads the common ones in a friendlier form.
 type Logrotate struct {
-	Filename string
-	MaxSize  int64
+	Filename            string                                              `json:"filename"`
+	MaxSize             int64                                               `json:"max_size"`
+	MaxBackups          int                                                 `json:"max_backups"`
+	MaxAge              time.Duration                                       `json:"max_age"`
+	MaxTotalSize        int64                                               `json:"max_total_size"`
+	Compress            bool                                                `json:"compress"`
+	CompressLevel       int                                                 `json:"compress_level"`
+	Compressor          Compressor                                          `json:"-"`
+	CompressMinSize     int64                                               `json:"compress_min_size"`
+	AsyncCleanup        bool                                                `json:"async_cleanup"`
+	FileMode            os.FileMode                                         `json:"file_mode"`
+	DirMode             os.FileMode                                         `json:"dir_mode"`
+	Uid                 int                                                 `json:"uid"`
+	Gid                 int                                                 `json:"gid"`
+	SetOwner            bool                                                `json:"set_owner"`
+	StrictMode          bool                                                `json:"strict_mode"`
+	RotationInterval    time.Duration                                       `json:"rotation_interval"`
+	BackupTimeFormat    string                                              `json:"backup_time_format"`
+	BackupMode          BackupMode                                          `json:"backup_mode"`
+	UTC                 bool                                                `json:"utc"`
+	OnRotate            func(oldPath, newPath string)                       `json:"-"`
+	Symlink             string                                              `json:"symlink"`
+	Header              []byte                                              `json:"header"`
+	HeaderFunc          func() []byte                                       `json:"-"`
+	BufferSize          int                                                 `json:"buffer_size"`
+	StartupMode         StartupMode                                         `json:"startup_mode"`
+	MaxLines            int                                                 `json:"max_lines"`
+	CountWrites         bool                                                `json:"count_writes"`
+	RotateMode          RotateMode                                          `json:"rotate_mode"`
+	BackupNameFunc      func(filename string, t time.Time) string           `json:"-"`
+	SyncDir             bool                                                `json:"sync_dir"`
+	WatchActive         bool                                                `json:"watch_active"`
+	ArchiveDir          string                                              `json:"archive_dir"`
+	KeepMinimum         int                                                 `json:"keep_minimum"`
+	AtomicCompress      bool                                                `json:"atomic_compress"`
+	MaxOpenDuration     time.Duration                                       `json:"max_open_duration"`
+	FlushInterval       time.Duration                                       `json:"flush_interval"`
+	NoCreateDir         bool                                                `json:"no_create_dir"`
+	CompressOnClose     bool                                                `json:"compress_on_close"`
+	BestEffort          bool                                                `json:"best_effort"`
+	ErrorHandler        func(err error)                                     `json:"-"`
+	CompressExisting    bool                                                `json:"compress_existing"`
+	MinRotateInterval   time.Duration                                       `json:"min_rotate_interval"`
+	Preallocate         bool                                                `json:"preallocate"`
+	InfixTimestamp      bool                                                `json:"infix_timestamp"`
+	PostRotate          func(backupPath string) (newPath string, err error) `json:"-"`
+	InstanceID          string                                              `json:"instance_id"`
+	SyncOnRotate        bool                                                `json:"sync_on_rotate"`
+	MinFreeDisk         int64                                               `json:"min_free_disk"`
+	HashBackups         bool                                                `json:"hash_backups"`
+	MoveFunc            func(src, dst string) error                         `json:"-"`
+	FastRotate          bool                                                `json:"fast_rotate"`
+	RecordDelimiter     []byte                                              `json:"record_delimiter"`
+	OpenReadWrite       bool                                                `json:"open_read_write"`
+	ShouldRotate        func(current *Logrotate, pendingWrite []byte) bool  `json:"-"`
+	SizeResyncInterval  time.Duration                                       `json:"size_resync_interval"`
+	CompressCommand     []string                                            `json:"compress_command"`
+	CompressExt         string                                              `json:"compress_ext"`
+	WriteTimeout        time.Duration                                       `json:"write_timeout"`
+	DisableSizeRotation bool                                                `json:"disable_size_rotation"`
+	RepairBackups       bool                                                `json:"repair_backups"`
+	Footer              []byte                                              `json:"footer"`
+	FooterFunc          func() []byte                                       `json:"-"`
+	RotateRetries       int                                                 `json:"rotate_retries"`
+	RotateRetryBackoff  time.Duration                                       `json:"rotate_retry_backoff"`
+	RejectBinary        bool                                                `json:"reject_binary"`
+	SlidingWindow       bool                                                `json:"sliding_window"`
+	NewFileTruncate     bool                                                `json:"new_file_truncate"`
+	CompressBufferSize  int                                                 `json:"compress_buffer_size"`
+	EpochFunc           func() string                                       `json:"-"`
+	Secondary           io.Writer                                           `json:"-"`
+	CloseSecondary      bool                                                `json:"close_secondary"`
+	CompressInPlace     bool                                                `json:"compress_in_place"`
+	RetentionPolicy     RetentionPolicy                                     `json:"retention_policy"`
+	CronSchedule        string                                              `json:"cron_schedule"`
 
-	mu   sync.Mutex
-	file *os.File
-	size int64
+	mu         sync.Mutex
+	file       file
+	buf        *bufio.Writer // in front of file with BufferSize.
+	size       int64
+	lines      int       // records written to file for MaxLines.
+	tail       []byte    // partial RecordDelimiter at the end of the last write.
+	openTime   time.Time // when the data of file started.
+	clock      Clock
+	fs         fileSystem
+	rotated    []RotationEvent // for OnRotate and events.
+	pending    RotationEvent   // the rotation in progress.
+	rotateTime time.Time       // for backup names, set by RotateAll.
+	rotateSeq  int             // least ".N" suffix of backup names, by RotateAll.
+	events     chan<- RotationEvent
+	diag       *diagnostics
+	epoch      string  // of EpochFunc when the file was opened.
+	errs       []error // for ErrorHandler.
+	optErrs    []error // of options, returned by Validate.
+
+	cacheMu   sync.Mutex // the mill scans backups without mu.
+	dirCaches map[string]*dirCache
+	queued    map[string]bool // backups waiting for the mill, by cacheMu.
+	postDirs  map[string]bool // backup directories of PostRotate, by cacheMu.
+	started   bool            // StartupMode was applied.
+
+	secondaryClosed bool // Close closed Secondary with CloseSecondary.
+
+	rotations    atomic.Uint64
+	lastRotation time.Time     // for MinRotateInterval and LastRotation.
+	freeChecked  time.Time     // last check of MinFreeDisk.
+	sizeChecked  time.Time     // last check of SizeResyncInterval.
+	cron         *cronSchedule // parsed CronSchedule.
+	cronFrom     time.Time     // openTime when cronNext was found.
+	cronNext     time.Time     // first time of CronSchedule after cronFrom.
+
+	millCh      chan string // backups for the mill.
+	millDone    chan struct{}
+	millPending sync.WaitGroup // backups queued but not handled yet.
+	errMu       sync.Mutex
+	millErr     error
+	millErrs    []error // of the mill for ErrorHandler, by errMu.
+
+	flushStop chan struct{} // closed to stop flushing with FlushInterval.
+	flushDone chan struct{}
 }
 
-// Write implements io.Writer, and write data in current file.
+// Write implements io.Writer, and write data in current file. Data larger
+// than MaxSize is written whole into a fresh file which then exceeds MaxSize.
+// A failed or short write returns the count written with the error, or
+// io.ErrShortWrite, and the next Write opens the file again. Writing thus
+// recovers by itself, for example once a full disk has space again.
 func (l *Logrotate) Write(p []byte) (n int, err error) {
 	l.mu.Lock()
-	defer l.mu.Unlock()
+	defer l.unlock()
+
+	if l.RejectBinary && binaryData(p) {
+		return 0, fmt.Errorf("%w: %q", ErrBinaryData, l.Filename)
+	}
+
+	records := l.records(p)
+	err = l.prepareWrite(p, int64(len(p)), records)
+	if err != nil {
+		return l.failed(len(p), 0, err)
+	}
+
+	n, err = l.write(p)
+	l.lines += records
+	if err != nil {
+		return l.failed(len(p), n, fmt.Errorf("logrotate: write %q: %w", l.Filename, err))
+	}
+
+	l.mirror(p)
+	return n, nil
+}
+
+// WriteString implements io.StringWriter, it is like Write without
+// converting s to a byte slice.
+func (l *Logrotate) WriteString(s string) (n int, err error) {
+	l.mu.Lock()
+	defer l.unlock()
+
+	if l.RejectBinary && binaryString(s) {
+		return 0, fmt.Errorf("%w: %q", ErrBinaryData, l.Filename)
+	}
+
+	records := l.recordsString(s)
+	var p []byte
+	if l.ShouldRotate != nil {
+		p = []byte(s)
+	}
+	err = l.prepareWrite(p, int64(len(s)), records)
+	if err != nil {
+		return l.failed(len(s), 0, err)
+	}
 
-	writeLen := int64(len(p))
+	n, err = l.writeString(s)
+	l.lines += records
+	if err != nil {
+		return l.failed(len(s), n, fmt.Errorf("logrotate: write %q: %w", l.Filename, err))
+	}
+
+	l.mirrorString(s)
+	return n, nil
+}
+
+// failed returns the result of a write of want bytes which stopped after n
+// bytes with err. With BestEffort err is only reported and the write counts
+// as complete.
+func (l *Logrotate) failed(want, n int, err error) (int, error) {
+	if !l.BestEffort {
+		return n, err
+	}
+
+	l.reportError(err)
+	return want, nil
+}
 
-	if writeLen > l.MaxSize {
-		return 0, errors.New("write length exceeds maximum file size")
+// reportError remembers err for ErrorHandler, which is called by unlock.
+func (l *Logrotate) reportError(err error) {
+	if err != nil {
+		l.diagf("error: %v", err)
+	}
+	if l.ErrorHandler != nil && err != nil {
+		l.errs = append(l.errs, err)
+	}
+}
+
+// prepareWrite opens the file and rotates it if writeLen more bytes or
+// records more records do not fit, or if ShouldRotate asks for it with the
+// data p.
+func (l *Logrotate) prepareWrite(p []byte, writeLen int64, records int) error {
+	if l.WatchActive && l.file != nil && l.fileReplaced() {
+		err := l.closeFile()
+		if err != nil {
+			return err
+		}
 	}
 
 	if l.file == nil {
 		err := l.createFile()
 		if err != nil {
-			return 0, err
+			return err
+		}
+	}
+
+	l.resyncSize()
+
+	if l.lowDisk() {
+		return l.reclaimDisk()
+	}
+
+	if l.size == 0 && l.EpochFunc != nil {
+		// an empty file belongs to the current epoch.
+		l.epoch = l.EpochFunc()
+	}
+
+	if reason, ok := l.rotateDue(writeLen, records); ok {
+		return l.rotateForWrite(reason)
+	}
+
+	if l.ShouldRotate != nil && !l.rotatedRecently() && l.ShouldRotate(l, p) {
+		return l.rotateForWrite(RotateCustom)
+	}
+
+	return nil
+}
+
+// rotateForWrite rotates the file before a write. If only the backup name
+// is taken by a directory the error is reported and the write goes to the
+// current file.
+func (l *Logrotate) rotateForWrite(reason RotateReason) error {
+	if reason == RotateSize && l.SlidingWindow {
+		return l.slideWindow()
+	}
+
+	err := l.rotateFile(reason)
+	if (errors.Is(err, ErrBackupIsDir) || errors.Is(err, ErrRetentionExceeded)) && l.file != nil {
+		l.reportError(err)
+		return nil
+	}
+	return err
+}
+
+// fileReplaced reports whether Filename was removed or replaced since the
+// current file was opened.
+func (l *Logrotate) fileReplaced() bool {
+	info, err := l.filesystem().Stat(l.Filename)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+
+	cur, err := l.file.Stat()
+	if err != nil {
+		return false
+	}
+	return !os.SameFile(info, cur)
+}
+
+// rotatedRecently reports whether the last rotation is less than
+// MinRotateInterval ago.
+func (l *Logrotate) rotatedRecently() bool {
+	return l.MinRotateInterval > 0 && !l.lastRotation.IsZero() && l.timeNow().Sub(l.lastRotation) < l.MinRotateInterval
+}
+
+// rotateDue reports whether and why the current file must be rotated before
+// writing writeLen bytes holding records records. An empty file takes a
+// write of any size, rotating it would leave an empty backup.
+func (l *Logrotate) rotateDue(writeLen int64, records int) (RotateReason, bool) {
+	return l.rotateDueFor(l.size, l.lines, l.openTime, writeLen, records, false)
+}
+
+// rotateDueFor is rotateDue for a file of size bytes and lines records
+// opened at openTime. With probe it changes nothing and does not call
+// EpochFunc, so a new epoch is not seen.
+func (l *Logrotate) rotateDueFor(size int64, lines int, openTime time.Time, writeLen int64, records int, probe bool) (RotateReason, bool) {
+	if l.rotatedRecently() {
+		return 0, false
+	}
+	if !l.DisableSizeRotation && size > 0 && writeLen+size > l.maxSize() {
+		return RotateSize, true
+	}
+	if l.MaxLines > 0 && lines > 0 && lines+records > l.MaxLines {
+		return RotateLines, true
+	}
+	if !probe && size > 0 && l.epochChanged() {
+		return RotateEpoch, true
+	}
+	return RotateTime, l.intervalPassed(openTime) || l.openExpired(openTime) || l.cronPassed(openTime, probe)
+}
+
+// countRecords is records without keeping the end of p for the next write.
+func (l *Logrotate) countRecords(p []byte) int {
+	if l.CountWrites {
+		return 1
+	}
+
+	delim := l.delimiter()
+	if len(delim) == 1 {
+		return bytes.Count(p, delim)
+	}
+	return bytes.Count(append(l.tail[:len(l.tail):len(l.tail)], p...), delim)
+}
+
+// records returns the number of records in p counted for MaxLines. A
+// delimiter split between writes is counted with the write completing it.
+func (l *Logrotate) records(p []byte) int {
+	if l.CountWrites {
+		return 1
+	}
+
+	delim := l.delimiter()
+	if len(delim) == 1 {
+		return bytes.Count(p, delim)
+	}
+
+	// the tail is shorter than delim, it can only hold a partial one.
+	data := append(l.tail, p...)
+	n := bytes.Count(data, delim)
+	keep := len(delim) - 1
+	if keep > len(data) {
+		keep = len(data)
+	}
+	l.tail = append(l.tail[:0], data[len(data)-keep:]...)
+	return n
+}
+
+// cutRecords returns the length of the start of p holding n records, or
+// len(p) if it holds less. Like records, it counts a delimiter begun in the
+// previous write.
+func (l *Logrotate) cutRecords(p []byte, n int) int {
+	delim := l.delimiter()
+	end := 0
+	for k := len(delim) - 1; k > 0; k-- {
+		if bytes.HasSuffix(l.tail, delim[:k]) && bytes.HasPrefix(p, delim[k:]) {
+			end = len(delim) - k
+			n--
+			break
+		}
+	}
+
+	for ; n > 0; n-- {
+		i := bytes.Index(p[end:], delim)
+		if i < 0 {
+			return len(p)
+		}
+		end += i + len(delim)
+	}
+	return end
+}
+
+// recordsString is records for a string.
+func (l *Logrotate) recordsString(s string) int {
+	if l.CountWrites {
+		return 1
+	}
+
+	delim := l.delimiter()
+	if len(delim) == 1 {
+		return strings.Count(s, string(delim))
+	}
+	return l.records([]byte(s))
+}
+
+// delimiter returns RecordDelimiter, or a newline if it is empty.
+func (l *Logrotate) delimiter() []byte {
+	if len(l.RecordDelimiter) == 0 {
+		return []byte{'\n'}
+	}
+	return l.RecordDelimiter
+}
+
+// ReadFrom implements io.ReaderFrom, so io.Copy writes large chunks with one
+// lock each, unless the source implements io.WriterTo. Unlike Write, data is
+// split between files at MaxSize and after MaxLines records, so no file
+// exceeds them.
+func (l *Logrotate) ReadFrom(r io.Reader) (n int64, err error) {
+	buf := make([]byte, readFromSize)
+
+	// with RejectBinary a character split between two reads is carried
+	// over to the next one.
+	carry := 0
+	for {
+		m, rerr := r.Read(buf[carry:])
+		m += carry
+		carry = 0
+		if l.RejectBinary && rerr == nil {
+			carry = partialRune(buf[:m])
+			m -= carry
+		}
+
+		if m > 0 {
+			l.mu.Lock()
+			var written int
+			var err error
+			if l.RejectBinary && binaryData(buf[:m]) {
+				err = fmt.Errorf("%w: %q", ErrBinaryData, l.Filename)
+			} else {
+				written, err = l.writeSplit(buf[:m])
+				if err != nil {
+					written, err = l.failed(m, written, err)
+				}
+			}
+			l.unlock()
+
+			n += int64(written)
+			if err != nil {
+				return n, err
+			}
+		}
+		copy(buf, buf[m:m+carry])
+
+		if rerr == io.EOF {
+			return n, nil
+		}
+		if rerr != nil {
+			return n, rerr
 		}
 	}
+}
+
+// writeSplit writes p rotating the file each time it reaches MaxSize or
+// MaxLines.
+func (l *Logrotate) writeSplit(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		// the file only has to take the first byte, the rest is split off
+		// below.
+		err := l.prepareWrite(p, 1, 1)
+		if err != nil {
+			return n, err
+		}
+
+		chunk := p
+		if room := l.maxSize() - l.size; !l.DisableSizeRotation && room > 0 && int64(len(chunk)) > room {
+			chunk = chunk[:room]
+		}
+		if left := l.MaxLines - l.lines; l.MaxLines > 0 && !l.CountWrites && left > 0 {
+			chunk = chunk[:l.cutRecords(chunk, left)]
+		}
 
-	if writeLen+l.size > l.MaxSize {
-		err := l.rotateFile()
+		m, err := l.write(chunk)
+		l.lines += l.records(chunk[:m])
+		n += m
 		if err != nil {
-			return 0, err
+			return n, fmt.Errorf("logrotate: write %q: %w", l.Filename, err)
 		}
+		l.mirror(chunk)
+
+		p = p[m:]
 	}
 
-	n, err = l.file.Write(p)
-	l.size += int64(n)
+	return n, nil
+}
 
-	return n, err
+// WriteSyncer is an io.Writer which commits its data with Sync, the same
+// method set as zapcore.WriteSyncer. A *Logrotate can be used as a zap sink
+// without an adapter.
+type WriteSyncer interface {
+	io.Writer
+	Sync() error
 }
 
-// Close implements io.Closer, and closes the current file.
-func (l *Logrotate) Close() error {
+var _ WriteSyncer = (*Logrotate)(nil)
+
+// Rotator is the part of Logrotate used by code writing logs, so it can be
+// replaced in tests, for example by logrotatetest.Writer.
+type Rotator interface {
+	io.WriteCloser
+	Rotate() error
+	Size() int64
+	Rotations() uint64
+	CurrentFile() string
+}
+
+var _ Rotator = (*Logrotate)(nil)
+
+// ReadTail returns up to the last n bytes of the current file, including
+// buffered data, and an error for a negative n. It reads through the open
+// file with OpenReadWrite, and opens Filename for reading otherwise.
+func (l *Logrotate) ReadTail(n int) ([]byte, error) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	return l.closeFile()
+
+	return l.readTail(n)
 }
 
-// close closes the file if it is open.
-func (l *Logrotate) closeFile() error {
-	if l.file == nil {
-		return nil
+// readTail does the work of ReadTail with mu held.
+func (l *Logrotate) readTail(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("logrotate: negative ReadTail length %d", n)
 	}
-	err := l.file.Close()
-	l.file = nil
-	return err
-}
 
-func (l *Logrotate) createFile() error {
-	if _, err := os.Stat(filepath.Dir(l.Filename)); os.IsNotExist(err) {
-		err := os.Mkdir(filepath.Dir(l.Filename), 0777)
+	err := l.flush()
+	if err != nil {
+		return nil, err
+	}
+
+	var r file = l.file
+	if r == nil || !l.OpenReadWrite {
+		f, err := os.Open(l.Filename)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("logrotate: open %q: %w", l.Filename, err)
 		}
+		defer f.Close()
+		r = f
+	}
+
+	info, err := r.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("logrotate: stat %q: %w", l.Filename, err)
+	}
+
+	off := info.Size() - int64(n)
+	if off < 0 {
+		off = 0
+	}
+
+	buf := make([]byte, info.Size()-off)
+	m, err := r.ReadAt(buf, off)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("logrotate: read %q: %w", l.Filename, err)
+	}
+	return buf[:m], nil
+}
+
+// Flush writes the data buffered with BufferSize to the file, without
+// syncing it like Sync. It does nothing without a buffer.
+func (l *Logrotate) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.flush()
+}
+
+// Sync flushes the buffer and commits the current file to stable storage.
+// It does nothing if no file is open.
+func (l *Logrotate) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
 	}
 
-	f, err := os.OpenFile(l.Filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	err := l.flush()
 	if err != nil {
 		return err
 	}
 
-	l.file = f
-	l.size = 0
+	err = l.file.Sync()
+	if err != nil {
+		return fmt.Errorf("logrotate: sync %q: %w", l.Filename, err)
+	}
 	return nil
 }
 
-func (l *Logrotate) rotateFile() error {
+// Reopen closes the current file and opens Filename again without making a
+// backup. It picks up a file moved away by external tools like logrotate(8).
+func (l *Logrotate) Reopen() error {
+	l.mu.Lock()
+	defer l.unlock()
+
+	return l.reopen()
+}
+
+// reopen does the work of Reopen with mu held.
+func (l *Logrotate) reopen() error {
 	err := l.closeFile()
 	if err != nil {
 		return err
 	}
 
-	err = os.Rename(l.Filename, l.backupName())
+	return l.createFile()
+}
+
+// Reset closes the current file and opens it again truncated, dropping the
+// data without making a backup.
+func (l *Logrotate) Reset() error {
+	l.mu.Lock()
+	defer l.unlock()
+
+	err := l.closeFile()
 	if err != nil {
 		return err
 	}
 
+	err = os.Truncate(l.Filename, 0)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("logrotate: truncate %q: %w", l.Filename, err)
+	}
+
 	return l.createFile()
 }
 
-func (l *Logrotate) backupName() string {
-	return l.Filename + "." + time.Now().Format("2006.01.02_15:04:05")
+// Rotate closes the current file, moves it to a backup and opens a new one
+// regardless of its size. A new empty file is created if there is nothing
+// to rotate.
+func (l *Logrotate) Rotate() error {
+	l.mu.Lock()
+	defer l.unlock()
+
+	return l.rotate()
+}
+
+// RotateIfNeeded rotates the open file if the next Write would, because it
+// is full by MaxSize or MaxLines, by time, EpochFunc or ShouldRotate, which
+// gets no data. It reports whether it rotated, for schedulers driving the
+// rotation themselves.
+func (l *Logrotate) RotateIfNeeded() (rotated bool, err error) {
+	l.mu.Lock()
+	defer l.unlock()
+
+	if l.file == nil {
+		return false, nil
+	}
+
+	reason, ok := l.rotateDue(1, 1)
+	if !ok && l.ShouldRotate != nil && !l.rotatedRecently() && l.ShouldRotate(l, nil) {
+		reason, ok = RotateCustom, true
+	}
+	if !ok {
+		return false, nil
+	}
+
+	before := l.rotations.Load()
+	err = l.rotateForWrite(reason)
+	return l.rotations.Load() != before, err
+}
+
+// rotate does the work of Rotate with mu held.
+func (l *Logrotate) rotate() error {
+	if l.file == nil {
+		if _, err := l.filesystem().Stat(l.Filename); os.IsNotExist(err) {
+			return l.createFile()
+		}
+	}
+
+	return l.rotateFile(RotateManual)
+}
+
+// Drain flushes the buffer, rotates the open file unless it is empty, and
+// applies the retention once the compression and cleanup running in
+// background have finished. Unlike Close it leaves the writer open, and
+// writes wait until Drain returns.
+func (l *Logrotate) Drain() error {
+	l.mu.Lock()
+	err := l.drain()
+	l.unlock()
+
+	l.errMu.Lock()
+	defer l.errMu.Unlock()
+	if err == nil {
+		err = l.millErr
+	}
+	l.millErr = nil
+	return err
+}
+
+// drain does the work of Drain with mu held.
+func (l *Logrotate) drain() error {
+	err := l.flush()
+	if err != nil {
+		return err
+	}
+
+	if l.file != nil && l.size > 0 {
+		err = l.rotateFile(RotateManual)
+		if err != nil {
+			return err
+		}
+	}
+
+	l.millPending.Wait()
+	return l.removeBackups()
+}
+
+// SetMaxSize changes the maximum size of file to size Mbyte while writes may
+// be running. The file is not rotated by the call, but a file already larger
+// than the new size is rotated by the next Write.
+func (l *Logrotate) SetMaxSize(size int64) error {
+	if size < 1 || size > (1<<63-1)/Megabyte {
+		return fmt.Errorf("logrotate: invalid max size %d Mbyte", size)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.MaxSize = size * Megabyte
+	return nil
+}
+
+// SetFilename switches the writer to the file path. The current file is
+// closed and, with move, moved to path, which must not exist yet. Otherwise
+// it is left in place and path is opened, appending to an existing file.
+// A moved file keeps its size, lines and open time for the next rotation
+// decision, and the file is not reopened if path is the current Filename.
+// A missing directory of path is created with DirMode unless NoCreateDir is
+// set. Backups already rotated stay where they are.
+func (l *Logrotate) SetFilename(path string, move bool) error {
+	if path == "" {
+		return ErrNoFilename
+	}
+
+	l.mu.Lock()
+	defer l.unlock()
+
+	if path == l.Filename && l.file != nil {
+		return nil
+	}
+
+	wasOpen := l.file != nil
+	lines, openTime, epoch := l.lines, l.openTime, l.epoch
+	err := l.closeFile()
+	if err != nil {
+		return err
+	}
+
+	moved := false
+	if move && path != l.Filename && exists(l.Filename) {
+		if exists(path) {
+			return fmt.Errorf("logrotate: move %q: %w", path, os.ErrExist)
+		}
+
+		err := l.makeDir(filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+
+		err = l.relocate(l.Filename, path)
+		if err != nil {
+			return err
+		}
+		moved = true
+	}
+
+	// the mill finds backups by Filename without mu. It is done before the
+	// switch, and it calls nothing which takes mu.
+	l.millPending.Wait()
+
+	l.Filename = path
+	err = l.createFile()
+	if err == nil && moved && wasOpen {
+		// it is still the same file, so it keeps its counters.
+		l.lines, l.openTime, l.epoch = lines, openTime, epoch
+	}
+	return err
+}
+
+// Close implements io.Closer, closes the current file and waits until the
+// background compression and cleanup finish. The first background error is
+// returned if closing the file succeeded. Close may be called more than
+// once, also concurrently, and a later Write opens the file again.
+func (l *Logrotate) Close() error {
+	return l.CloseContext(context.Background())
+}
+
+// CloseContext is like Close, but stops waiting for the background work when
+// ctx is done and returns ctx.Err(). The file is closed in any case and the
+// background work still completes.
+func (l *Logrotate) CloseContext(ctx context.Context) error {
+	l.mu.Lock()
+	wasOpen := l.file != nil
+	ferr := l.writeFooter()
+	err := l.closeFile()
+	if err == nil {
+		err = ferr
+	}
+	if err == nil && wasOpen && l.CompressOnClose && l.Compress {
+		err = l.compressActive()
+	}
+	l.closeSecondary()
+	l.unlock()
+
+	l.stopFlusher()
+	if werr := l.stopMill(ctx); werr != nil {
+		return werr
+	}
+
+	// pass the errors of the last work of the mill to ErrorHandler.
+	l.mu.Lock()
+	l.unlock()
+
+	l.errMu.Lock()
+	defer l.errMu.Unlock()
+	if err == nil {
+		err = l.millErr
+	}
+	l.millErr = nil
+	return err
+}
+
+// closeFile closes the file if it is open.
+func (l *Logrotate) closeFile() error {
+	if l.file == nil {
+		return nil
+	}
+	ferr := l.flush()
+	err := l.file.Close()
+	l.file = nil
+	l.buf = nil
+	if ferr != nil {
+		return ferr
+	}
+	if err != nil {
+		return fmt.Errorf("logrotate: close %q: %w", l.Filename, err)
+	}
+	return nil
+}
+
+// compressActive compresses the closed Filename with CompressOnClose. An
+// earlier compressed file is not replaced.
+func (l *Logrotate) compressActive() error {
+	err := l.checkCompress()
+	if err != nil {
+		return err
+	}
+
+	dst := l.Filename + l.compressExt()
+	if exists(dst) {
+		return fmt.Errorf("logrotate: compress %q: %q exists", l.Filename, dst)
+	}
+	return l.compress(l.Filename)
+}
+
+// createFile opens Filename for appending, creating it and its directory if
+// needed. On failure no file is set.
+func (l *Logrotate) createFile() error {
+	return l.openFile(0)
+}
+
+// createNew opens the new file after a rotation. With NewFileTruncate a
+// stale file at Filename is truncated.
+func (l *Logrotate) createNew() error {
+	if l.NewFileTruncate {
+		return l.openFile(os.O_TRUNC)
+	}
+	return l.openFile(0)
+}
+
+// openFile is createFile with the extra open flags.
+func (l *Logrotate) openFile(extra int) error {
+	if l.Filename == "" {
+		return ErrNoFilename
+	}
+
+	// MkdirAll does nothing for an existing directory, and recreates one
+	// removed since the last file was created. A bare file name is in the
+	// working directory.
+	dir := filepath.Dir(l.Filename)
+	if dir != "." {
+		err := l.makeDir(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	if info, err := l.filesystem().Stat(l.Filename); err == nil && info.IsDir() {
+		return fmt.Errorf("%w: %q", ErrFilenameIsDir, l.Filename)
+	}
+
+	err := l.startup()
+	if err != nil {
+		return err
+	}
+
+	flag := os.O_CREATE | os.O_APPEND | os.O_WRONLY | extra
+	if l.OpenReadWrite {
+		flag = os.O_CREATE | os.O_APPEND | os.O_RDWR | extra
+	}
+
+	var f file
+	err = l.retry(func() (err error) {
+		f, err = l.filesystem().OpenFile(l.Filename, flag, l.fileMode())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("logrotate: open %q: %w", l.Filename, err)
+	}
+
+	// count bytes already on disk, otherwise an existing file is allowed
+	// to grow past MaxSize after a restart.
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("logrotate: stat %q: %w", l.Filename, err)
+	}
+
+	err = l.chown(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	if l.StrictMode {
+		err = f.Chmod(l.fileMode())
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("logrotate: chmod %q: %w", l.Filename, err)
+		}
+	}
+
+	l.preallocate(f)
+
+	err = l.syncDir(l.Filename)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	if l.WriteTimeout > 0 {
+		f = timeoutFile{file: f, timeout: l.WriteTimeout}
+	}
+
+	l.file = f
+	if l.BufferSize > 0 {
+		l.buf = bufio.NewWriterSize(f, l.BufferSize)
+		l.startFlusher()
+	}
+	l.size = info.Size()
+	l.lines = 0
+	l.openTime = l.timeNow()
+	if l.EpochFunc != nil {
+		l.epoch = l.EpochFunc()
+	}
+	if l.size > 0 {
+		l.openTime = info.ModTime()
+	} else if err := l.writeHeader(); err != nil {
+		l.closeFile()
+		return err
+	}
+
+	l.diagf("open %q at %d bytes", l.Filename, l.size)
+	l.updateSymlink()
+	return nil
+}
+
+// rotateFile moves the current file to a backup and opens a new one. Once
+// the current file is closed any failure leaves no file set, so the next
+// Write opens Filename again and retries the rotation if it is still due.
+func (l *Logrotate) rotateFile(reason RotateReason) error {
+	err := l.checkRotate()
+	if err != nil {
+		return err
+	}
+
+	err = l.checkRetention()
+	if err != nil {
+		return err
+	}
+
+	err = l.writeFooter()
+	if err != nil {
+		return err
+	}
+
+	l.pending = RotationEvent{Size: l.size, Reason: reason}
+
+	if l.RotateMode == CopyTruncateMode && l.file != nil {
+		return l.copyTruncate()
+	}
+
+	err = l.syncRotated()
+	if err != nil {
+		return err
+	}
+
+	if l.FastRotate && renameOpen && l.file != nil {
+		return l.fastRotate()
+	}
+
+	err = l.closeFile()
+	if err != nil {
+		return err
+	}
+
+	name, err := l.moveToBackup()
+	if errors.Is(err, ErrBackupIsDir) {
+		// keep writing to the file not moved.
+		if cerr := l.createFile(); cerr != nil {
+			return cerr
+		}
+		return err
+	}
+	if l.fileGone(err) {
+		// nothing is left to back up, start over with a new file.
+		return l.createNew()
+	}
+	if err != nil {
+		return err
+	}
+
+	err = l.createNew()
+	if err != nil {
+		return err
+	}
+
+	l.rotatedTo(name)
+	return l.cleanup(name)
+}
+
+// fastRotate rotates with FastRotate. The open file is moved to the backup
+// and closed once the new file is open, if the move fails it stays in use.
+func (l *Logrotate) fastRotate() error {
+	err := l.flush()
+	if err != nil {
+		return err
+	}
+
+	name, err := l.moveToBackup()
+	gone := l.fileGone(err)
+	if err != nil && !gone {
+		return err
+	}
+
+	old := l.file
+	l.file = nil
+	l.buf = nil
+	err = l.createNew()
+	cerr := old.Close()
+	if err != nil || gone {
+		return err
+	}
+	if cerr != nil {
+		return fmt.Errorf("logrotate: close %q: %w", name, cerr)
+	}
+
+	l.rotatedTo(name)
+	return l.cleanup(name)
+}
+
+// fileGone reports whether the move to a backup failed with err because
+// Filename was removed, for example with its directory.
+func (l *Logrotate) fileGone(err error) bool {
+	return errors.Is(err, os.ErrNotExist) && !exists(l.Filename)
+}
+
+// syncRotated flushes and syncs the current file before it is moved with
+// SyncOnRotate.
+func (l *Logrotate) syncRotated() error {
+	if !l.SyncOnRotate || l.file == nil {
+		return nil
+	}
+
+	err := l.flush()
+	if err != nil {
+		return err
+	}
+
+	err = l.file.Sync()
+	if err != nil {
+		return fmt.Errorf("logrotate: sync %q: %w", l.Filename, err)
+	}
+	return nil
+}
+
+// checkRotate reports a configuration which prevents rotation.
+func (l *Logrotate) checkRotate() error {
+	if strings.ContainsAny(l.timeFormat(), `/`+string(filepath.Separator)) {
+		return fmt.Errorf("logrotate: backup time format %q contains path separator", l.timeFormat())
+	}
+	if strings.ContainsAny(l.InstanceID, `/`+string(filepath.Separator)) {
+		return fmt.Errorf("logrotate: instance id %q contains path separator", l.InstanceID)
+	}
+	if l.CronSchedule != "" {
+		if _, err := parseCron(l.CronSchedule); err != nil {
+			return err
+		}
+	}
+
+	return l.checkCompress()
+}
+
+// moveToBackup renames the closed Filename to a new backup, queues it for
+// compression and returns its name.
+func (l *Logrotate) moveToBackup() (string, error) {
+	name, err := l.nextBackup()
+	if err != nil {
+		return "", err
+	}
+
+	if l.compressInPlace() {
+		err = l.moveCompressed(name)
+	} else {
+		err = l.moveFile(l.Filename, name)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	err = l.syncDir(name)
+	if err != nil {
+		return "", err
+	}
+
+	name = l.postRotate(name)
+	l.queueBackup(name)
+	return name, nil
+}
+
+// postRotate passes the new backup name to PostRotate and returns the path
+// it was moved to. On failure the backup stays at name.
+func (l *Logrotate) postRotate(name string) string {
+	if l.PostRotate == nil {
+		return name
+	}
+
+	path, err := l.PostRotate(name)
+	if err != nil {
+		l.reportError(fmt.Errorf("logrotate: post rotate %q: %w", name, err))
+		return name
+	}
+	if path == "" || path == name {
+		return name
+	}
+
+	l.cacheMu.Lock()
+	if l.postDirs == nil {
+		l.postDirs = make(map[string]bool)
+	}
+	l.postDirs[filepath.Dir(path)] = true
+	l.cacheMu.Unlock()
+	return path
+}
+
+// nextBackup returns the name for a new backup, making room for it in
+// IndexMode.
+func (l *Logrotate) nextBackup() (string, error) {
+	err := l.makeBackupDir()
+	if err != nil {
+		return "", err
+	}
+
+	if l.BackupNameFunc != nil {
+		return checkBackupDir(l.BackupNameFunc(l.Filename, l.backupTime()))
+	}
+
+	if l.BackupMode != IndexMode {
+		// a directory counts as taken, another name is chosen.
+		return l.backupName(), nil
+	}
+
+	// a directory is not shifted, check before shifting the others.
+	name, err := checkBackupDir(l.indexName(1))
+	if err != nil {
+		return "", err
+	}
+
+	// the mill must not work on a backup while it is renamed.
+	l.millPending.Wait()
+
+	err = l.shiftBackups()
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// checkBackupDir returns name, or ErrBackupIsDir if it is a directory.
+func checkBackupDir(name string) (string, error) {
+	if info, err := os.Lstat(name); err == nil && info.IsDir() {
+		return "", fmt.Errorf("%w: %q", ErrBackupIsDir, name)
+	}
+	return name, nil
+}
+
+// queueBackup hands the new backup name to the mill with AsyncCleanup.
+func (l *Logrotate) queueBackup(name string) {
+	if !l.AsyncCleanup {
+		return
+	}
+
+	if l.Compress || l.HashBackups {
+		l.queueMill(name)
+	} else {
+		l.queueMill("")
+	}
+}
+
+// cleanup compresses and hashes the new backup name and removes old backups
+// after a rotation, unless the mill does.
+func (l *Logrotate) cleanup(name string) error {
+	if l.AsyncCleanup {
+		return nil
+	}
+
+	err := l.finishBackup(name)
+	if err != nil {
+		return err
+	}
+
+	return l.retentionError(l.removeBackups())
+}
+
+// startup applies StartupMode to an existing Filename, and CompressExisting
+// and the retention to existing backups, before Filename is opened for the
+// first time.
+func (l *Logrotate) startup() error {
+	if l.started {
+		return nil
+	}
+
+	err := l.repairBackups()
+	if err != nil {
+		return err
+	}
+
+	err = l.compressExisting()
+	if err != nil {
+		return err
+	}
+
+	switch l.StartupMode {
+	case TruncateMode:
+		err := os.Truncate(l.Filename, 0)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("logrotate: truncate %q: %w", l.Filename, err)
+		}
+
+	case RotateExistingMode:
+		info, err := l.filesystem().Stat(l.Filename)
+		if err != nil || info.Size() == 0 {
+			break
+		}
+
+		err = l.checkRotate()
+		if err != nil {
+			return err
+		}
+		if err := l.checkRetention(); err != nil {
+			// append to the existing file instead.
+			l.reportError(err)
+			break
+		}
+
+		l.pending = RotationEvent{Size: info.Size(), Reason: RotateStartup}
+		name, err := l.moveToBackup()
+		if err != nil {
+			return err
+		}
+
+		l.rotatedTo(name)
+		err = l.cleanup(name)
+		if err != nil {
+			return err
+		}
+		l.started = true
+		return nil
+	}
+
+	// backups left by an earlier run are subject to retention right away.
+	err = l.startRetention()
+	if err != nil {
+		return err
+	}
+
+	l.started = true
+	return nil
+}
+
+// rotatedTo records a successful rotation to the backup name, described by
+// pending.
+func (l *Logrotate) rotatedTo(name string) {
+	l.rotations.Add(1)
+	l.lastRotation = l.timeNow()
+
+	e := l.pending
+	e.OldPath = name
+	e.NewPath = l.Filename
+	e.Time = l.lastRotation
+	l.rotated = append(l.rotated, e)
+	l.diagf("rotate %q to %q by %s at %d bytes", e.NewPath, e.OldPath, e.Reason, e.Size)
+}
+
+// unlock releases the mutex and then calls OnRotate and sends events for the
+// rotations, and calls ErrorHandler for the errors reported while it was held.
+func (l *Logrotate) unlock() {
+	rotated := l.rotated
+	l.rotated = nil
+	errs := l.errs
+	l.errs = nil
+	onRotate, onError := l.OnRotate, l.ErrorHandler
+	l.mu.Unlock()
+
+	if onError != nil {
+		// the mill must not call ErrorHandler itself, which may wait for
+		// mu while mu waits for the mill.
+		l.errMu.Lock()
+		errs = append(errs, l.millErrs...)
+		l.millErrs = nil
+		l.errMu.Unlock()
+	}
+
+	if onRotate != nil {
+		for _, e := range rotated {
+			onRotate(e.OldPath, e.NewPath)
+		}
+	}
+	l.sendEvents(rotated)
+	for _, err := range errs {
+		onError(err)
+	}
+}
+
+// write writes p to the buffer or the file and counts the bytes written.
+func (l *Logrotate) write(p []byte) (int, error) {
+	var n int
+	var err error
+	if l.buf != nil {
+		n, err = l.buf.Write(p)
+	} else {
+		n, err = l.file.Write(p)
+	}
+	return n, l.wrote(n, len(p), err)
+}
+
+// writeString is write for a string.
+func (l *Logrotate) writeString(s string) (int, error) {
+	var n int
+	var err error
+	if l.buf != nil {
+		n, err = l.buf.WriteString(s)
+	} else {
+		n, err = l.file.WriteString(s)
+	}
+	return n, l.wrote(n, len(s), err)
+}
+
+// wrote counts n of want bytes written and returns the error of the write,
+// io.ErrShortWrite if fewer bytes were written without one. After a failed
+// write the file is dropped, so the next write opens it again and counts
+// the bytes really on disk instead of a size gone stale.
+func (l *Logrotate) wrote(n, want int, err error) error {
+	l.size += int64(n)
+	if err == nil && n < want {
+		err = io.ErrShortWrite
+	}
+
+	if err != nil {
+		l.file.Close()
+		l.file = nil
+		l.buf = nil
+	}
+	return err
+}
+
+// flush writes the buffered data to the file.
+func (l *Logrotate) flush() error {
+	if l.buf == nil {
+		return nil
+	}
+
+	err := l.buf.Flush()
+	if err != nil {
+		return fmt.Errorf("logrotate: write %q: %w", l.Filename, err)
+	}
+	return nil
+}
+
+// syncDir syncs the directory of the file name with SyncDir or
+// SyncOnRotate.
+func (l *Logrotate) syncDir(name string) error {
+	if !l.SyncDir && !l.SyncOnRotate {
+		return nil
+	}
+	return syncDir(filepath.Dir(name))
+}
+
+// makeDir creates the missing directory dir, or with NoCreateDir reports
+// that it is missing.
+func (l *Logrotate) makeDir(dir string) error {
+	if !l.NoCreateDir {
+		// umask and mkdir drop bits like setgid, a set DirMode is applied
+		// to the created directories in full.
+		var created []string
+		if l.DirMode != 0 {
+			for d := dir; !exists(d); d = filepath.Dir(d) {
+				created = append(created, d)
+				if filepath.Dir(d) == d {
+					break
+				}
+			}
+		}
+
+		err := l.filesystem().MkdirAll(dir, l.dirMode())
+		if err != nil {
+			return fmt.Errorf("logrotate: mkdir %q: %w", dir, err)
+		}
+
+		for _, d := range created {
+			err := os.Chmod(d, l.DirMode)
+			if err != nil {
+				return fmt.Errorf("logrotate: chmod %q: %w", d, err)
+			}
+		}
+		return nil
+	}
+
+	info, err := l.filesystem().Stat(dir)
+	if err != nil {
+		return fmt.Errorf("logrotate: dir %q is required with NoCreateDir: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("logrotate: %q is not a directory", dir)
+	}
+	return nil
+}
+
+// writeHeader writes the header to the new empty file.
+func (l *Logrotate) writeHeader() error {
+	header := l.Header
+	if l.HeaderFunc != nil {
+		header = l.HeaderFunc()
+	}
+	if len(header) == 0 {
+		return nil
+	}
+
+	_, err := l.write(header)
+	if err != nil {
+		return fmt.Errorf("logrotate: write header %q: %w", l.Filename, err)
+	}
+	return nil
+}
+
+// writeFooter writes the footer to the open file.
+func (l *Logrotate) writeFooter() error {
+	if l.file == nil {
+		return nil
+	}
+
+	footer := l.Footer
+	if l.FooterFunc != nil {
+		footer = l.FooterFunc()
+	}
+	if len(footer) == 0 {
+		return nil
+	}
+
+	_, err := l.write(footer)
+	if err != nil {
+		return fmt.Errorf("logrotate: write footer %q: %w", l.Filename, err)
+	}
+	return nil
+}
+
+// intervalPassed reports whether a file opened at openTime was opened
+// before the start of the current RotationInterval.
+func (l *Logrotate) intervalPassed(openTime time.Time) bool {
+	if l.RotationInterval <= 0 {
+		return false
+	}
+	return openTime.Before(intervalStart(l.timeNow(), l.RotationInterval))
+}
+
+// openExpired reports whether a file opened at openTime was opened
+// MaxOpenDuration or longer ago.
+func (l *Logrotate) openExpired(openTime time.Time) bool {
+	if l.MaxOpenDuration <= 0 {
+		return false
+	}
+	return l.timeNow().Sub(openTime) >= l.MaxOpenDuration
+}
+
+// intervalStart returns the start of the interval d containing t, counted
+// from local midnight. Intervals of a day or longer are counted in whole
+// days since the Unix epoch.
+func intervalStart(t time.Time, d time.Duration) time.Time {
+	year, month, day := t.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+
+	const dayLen = 24 * time.Hour
+	if d < dayLen {
+		return midnight.Add(t.Sub(midnight) / d * d)
+	}
+
+	days := int(d / dayLen)
+	epochDay := int(time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / int64(dayLen/time.Second))
+	return midnight.AddDate(0, 0, -(epochDay % days))
+}
+
+// maxSize returns MaxSize, or the default size if it is not positive.
+func (l *Logrotate) maxSize() int64 {
+	if l.MaxSize <= 0 {
+		return defaultSize * Megabyte
+	}
+	return l.MaxSize
+}
+
+// retry calls op until it succeeds, fails with an error which is not
+// transient, or RotateRetries retries are done.
+func (l *Logrotate) retry(op func() error) error {
+	backoff := l.RotateRetryBackoff
+	for i := 0; ; i++ {
+		err := op()
+		if err == nil || i >= l.RotateRetries || !transient(err) {
+			return err
+		}
+
+		l.sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// timeNow returns the current time of the Clock of l.
+func (l *Logrotate) timeNow() time.Time {
+	clock := l.clock
+	if clock == nil {
+		clock = realClock{}
+	}
+	return clock.Now()
+}
+
+func (l *Logrotate) fileMode() os.FileMode {
+	if l.FileMode == 0 {
+		return defaultFileMode
+	}
+	return l.FileMode
+}
+
+func (l *Logrotate) dirMode() os.FileMode {
+	if l.DirMode == 0 {
+		return defaultDirMode
+	}
+	return l.DirMode
+}
+
+// backupName returns a free name for the next backup. Rotations within the
+// same second get an increasing ".N" suffix instead of replacing a backup.
+func (l *Logrotate) backupName() string {
+	name, _ := l.backupNameSeq()
+	return name
+}
+
+// backupNameSeq is backupName also returning the N of its ".N" suffix, zero
+// without. The suffix is at least rotateSeq.
+func (l *Logrotate) backupNameSeq() (string, int) {
+	prefix, suffix := l.nameParts()
+	epoch := l.epochSuffix()
+	head := strings.TrimSuffix(l.backupBase(), filepath.Base(l.Filename))
+	stamp := prefix + l.backupTime().In(l.location()).Format(l.timeFormat())
+	name := head + stamp + epoch + suffix
+
+	// backups moved by PostRotate keep their names, avoid those too.
+	seq := -1
+	ext := l.compressExt()
+	for _, dir := range l.backupDirs() {
+		files, _ := l.scanDir(dir)
+		for _, f := range files {
+			s := f.name
+			if !strings.HasSuffix(s, suffix) {
+				continue
+			}
+			s = strings.TrimSuffix(s, suffix)
+			if !strings.HasPrefix(s, stamp) {
+				continue
+			}
+
+			// the sequence counts across EpochFunc tokens.
+			if i := strings.LastIndexByte(s, epochSep); i >= len(stamp) {
+				s = s[:i]
+			}
+
+			if s == stamp && seq < 0 {
+				seq = 0
+			}
+			if !strings.HasPrefix(s, stamp+".") {
+				continue
+			}
+			if n, err := strconv.Atoi(s[len(stamp)+1:]); err == nil && n > seq {
+				seq = n
+			}
+		}
+	}
+
+	if seq < l.rotateSeq-1 {
+		seq = l.rotateSeq - 1
+	}
+
+	// a directory listing may miss a backup which the mill moves to its
+	// compressed name meanwhile, the plain name is checked before the
+	// compressed one for that.
+	for {
+		if seq >= 0 {
+			name = head + stamp + "." + strconv.Itoa(seq+1) + epoch + suffix
+		}
+		if !exists(name) && !exists(name+ext) {
+			return name, seq + 1
+		}
+		seq++
+	}
+}
+
+// backupTime returns the time for the name of the next backup, the time of
+// RotateAll or now.
+func (l *Logrotate) backupTime() time.Time {
+	if !l.rotateTime.IsZero() {
+		return l.rotateTime
+	}
+	return l.timeNow()
+}
+
+// exists reports whether any file, including a directory, is at name.
+func exists(name string) bool {
+	_, err := os.Lstat(name)
+	return !os.IsNotExist(err)
+}
+
+// location returns the time zone of backup timestamps.
+func (l *Logrotate) location() *time.Location {
+	if l.UTC {
+		return time.UTC
+	}
+	return time.Local
+}
+
+func (l *Logrotate) timeFormat() string {
+	if l.BackupTimeFormat == "" {
+		return backupTimeFormat
+	}
+	return l.BackupTimeFormat
 }
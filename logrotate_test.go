@@ -0,0 +1,1707 @@
+package logrotate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// testClock is a Clock and Sleeper which only moves when told, starting at
+// a fixed local time.
+type testClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{t: time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local)}
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *testClock) Sleep(d time.Duration) {
+	c.Advance(d)
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// umask returns the umask of the process, found by creating a file.
+func umask() os.FileMode {
+	dir, err := os.MkdirTemp("", "umask")
+	if err != nil {
+		return 0
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "f")
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY, 0777)
+	if err != nil {
+		return 0
+	}
+	f.Close()
+	info, err := os.Stat(name)
+	if err != nil {
+		return 0
+	}
+	return 0777 &^ info.Mode().Perm()
+}
+
+// testFilename returns the path of a log file in a new temporary directory.
+func testFilename(t *testing.T) string {
+	t.Helper()
+	return filepath.Join(t.TempDir(), "app.log")
+}
+
+// write writes s to l and fails the test on an error or short write.
+func write(t *testing.T, l *Logrotate, s string) {
+	t.Helper()
+	n, err := l.Write([]byte(s))
+	if err != nil {
+		t.Fatalf("Write(%q): %v", s, err)
+	}
+	if n != len(s) {
+		t.Fatalf("Write(%q) = %d, want %d", s, n, len(s))
+	}
+}
+
+// closeLog closes l and fails the test on an error.
+func closeLog(t *testing.T, l *Logrotate) {
+	t.Helper()
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+// readFile returns the content of name.
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// dirNames returns the sorted names of the files in dir.
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// backupFiles returns the sorted names of the files in dir other than the
+// log file "app.log".
+func backupFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var names []string
+	for _, name := range dirNames(t, dir) {
+		if name != "app.log" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// backupContents returns the contents of the uncompressed backups in dir
+// from oldest to newest.
+func backupContents(t *testing.T, dir string) []string {
+	t.Helper()
+	var contents []string
+	for _, name := range backupFiles(t, dir) {
+		contents = append(contents, readFile(t, filepath.Join(dir, name)))
+	}
+	return contents
+}
+
+func TestOpenCountsExistingSize(t *testing.T) {
+	filename := testFilename(t)
+	if err := os.WriteFile(filename, make([]byte, 8*Megabyte), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l := NewLogrotateT(filename, 10)
+	defer closeLog(t, l)
+
+	if _, err := l.Write(make([]byte, 4*Megabyte)); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.Size(); got != 4*Megabyte {
+		t.Fatalf("size = %d, want %d", got, 4*Megabyte)
+	}
+	if n := len(backupFiles(t, filepath.Dir(filename))); n != 1 {
+		t.Fatalf("got %d backups, want 1", n)
+	}
+}
+
+func TestOpenAppendsBelowMaxSize(t *testing.T) {
+	filename := testFilename(t)
+	if err := os.WriteFile(filename, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l := &Logrotate{Filename: filename, MaxSize: 10}
+	write(t, l, "new\n")
+	closeLog(t, l)
+
+	if got := readFile(t, filename); got != "old\nnew\n" {
+		t.Fatalf("file = %q", got)
+	}
+	if got := backupFiles(t, filepath.Dir(filename)); len(got) != 0 {
+		t.Fatalf("backups = %q, want none", got)
+	}
+}
+
+func TestRotateBelowMaxSize(t *testing.T) {
+	filename := testFilename(t)
+	l := NewLogrotateT(filename, 10)
+	defer closeLog(t, l)
+
+	write(t, l, "before\n")
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	write(t, l, "after\n")
+
+	if got := backupContents(t, filepath.Dir(filename)); len(got) != 1 || got[0] != "before\n" {
+		t.Fatalf("backups = %q", got)
+	}
+	if got := readFile(t, filename); got != "after\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
+
+func TestRotateWithoutFile(t *testing.T) {
+	filename := testFilename(t)
+	l := NewLogrotateT(filename, 10)
+	defer closeLog(t, l)
+
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := dirNames(t, filepath.Dir(filename)); len(got) != 1 || got[0] != "app.log" {
+		t.Fatalf("files = %q, want only an empty app.log", got)
+	}
+}
+
+func TestRotateClosedFile(t *testing.T) {
+	filename := testFilename(t)
+	l := NewLogrotateT(filename, 10)
+	write(t, l, "data\n")
+	closeLog(t, l)
+
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	closeLog(t, l)
+
+	if got := backupContents(t, filepath.Dir(filename)); len(got) != 1 || got[0] != "data\n" {
+		t.Fatalf("backups = %q", got)
+	}
+}
+
+func TestWriteCreatesNestedDirs(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "myapp", "sub")
+	l := NewLogrotateT(filepath.Join(dir, "app.log"), 10)
+	write(t, l, "x")
+	closeLog(t, l)
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != defaultDirMode&^umask() {
+		t.Fatalf("dir mode = %v, want %v", info.Mode().Perm(), defaultDirMode&^umask())
+	}
+}
+
+func TestFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no permission bits on Windows")
+	}
+
+	dir := filepath.Join(t.TempDir(), "logs")
+	filename := filepath.Join(dir, "app.log")
+	l := &Logrotate{Filename: filename, MaxSize: 10, FileMode: 0600, DirMode: 0700}
+	defer closeLog(t, l)
+
+	write(t, l, "123456789\n")
+	write(t, l, "rotated\n")
+
+	for _, name := range dirNames(t, dir) {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != 0600 {
+			t.Errorf("%s: mode = %v, want 0600", name, got)
+		}
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != 0700 {
+		t.Errorf("dir mode = %v, want 0700", got)
+	}
+}
+
+func TestWriteLargerThanMaxSize(t *testing.T) {
+	filename := testFilename(t)
+	l := NewLogrotateT(filename, 1)
+	defer closeLog(t, l)
+
+	write(t, l, "small\n")
+	big := bytes.Repeat([]byte("x"), 2*int(Megabyte))
+	n, err := l.Write(big)
+	if err != nil || n != len(big) {
+		t.Fatalf("Write = %d, %v, want %d, nil", n, err, len(big))
+	}
+
+	if got := readFile(t, filename); got != string(big) {
+		t.Fatalf("file holds %d bytes, want the %d of the write", len(got), len(big))
+	}
+	if got := backupContents(t, filepath.Dir(filename)); len(got) != 1 || got[0] != "small\n" {
+		t.Fatalf("backups = %q", got)
+	}
+
+	// the next write goes to a new file again.
+	write(t, l, "next\n")
+	if got := readFile(t, filename); got != "next\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
+
+func TestRotationIntervalDaily(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	l := newLogrotate(filename, WithClock(clock))
+	l.RotationInterval = 24 * time.Hour
+	defer closeLog(t, l)
+
+	write(t, l, "day 1\n")
+	clock.Advance(13 * time.Hour) // 23:00
+	write(t, l, "day 1 late\n")
+	if n := len(backupFiles(t, filepath.Dir(filename))); n != 0 {
+		t.Fatalf("got %d backups before midnight", n)
+	}
+
+	clock.Advance(2 * time.Hour) // 01:00 the next day
+	write(t, l, "day 2\n")
+	write(t, l, "day 2 again\n")
+
+	if got := backupContents(t, filepath.Dir(filename)); len(got) != 1 || got[0] != "day 1\nday 1 late\n" {
+		t.Fatalf("backups = %q", got)
+	}
+	if got := readFile(t, filename); got != "day 2\nday 2 again\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
+
+func TestIntervalStart(t *testing.T) {
+	loc := time.FixedZone("test", 2*3600)
+	at := func(day, hour, min int) time.Time { return time.Date(2024, 3, day, hour, min, 0, 0, loc) }
+	tests := []struct {
+		t    time.Time
+		d    time.Duration
+		want time.Time
+	}{
+		{at(5, 13, 45), time.Hour, at(5, 13, 0)},
+		{at(5, 13, 45), 15 * time.Minute, at(5, 13, 45)},
+		{at(5, 13, 44), 15 * time.Minute, at(5, 13, 30)},
+		{at(5, 13, 45), 6 * time.Hour, at(5, 12, 0)},
+		{at(5, 0, 0), 24 * time.Hour, at(5, 0, 0)},
+		{at(5, 23, 59), 24 * time.Hour, at(5, 0, 0)},
+	}
+	for _, tt := range tests {
+		if got := intervalStart(tt.t, tt.d); !got.Equal(tt.want) {
+			t.Errorf("intervalStart(%v, %v) = %v, want %v", tt.t, tt.d, got, tt.want)
+		}
+	}
+
+	// longer intervals start at a midnight, the same for every day in them.
+	start := intervalStart(at(5, 12, 0), 7*24*time.Hour)
+	if start.Hour() != 0 || start.Minute() != 0 || at(5, 12, 0).Sub(start) >= 7*24*time.Hour {
+		t.Errorf("weekly start = %v", start)
+	}
+	for d := 0; d < 7; d++ {
+		day := start.AddDate(0, 0, d).Add(time.Hour)
+		if got := intervalStart(day, 7*24*time.Hour); !got.Equal(start) {
+			t.Errorf("intervalStart(%v) = %v, want %v", day, got, start)
+		}
+	}
+}
+
+func TestOnRotate(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(clock))
+	defer closeLog(t, l)
+
+	type call struct{ old, new string }
+	var calls []call
+	l.OnRotate = func(oldPath, newPath string) {
+		// the lock is released, so the callback may use l.
+		calls = append(calls, call{oldPath, newPath})
+		_ = l.Size()
+	}
+
+	write(t, l, "123456789\n")
+	write(t, l, "automatic\n")
+	clock.Advance(time.Second)
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	first := filename + "." + clock.Now().Add(-time.Second).Format(backupTimeFormat)
+	second := filename + "." + clock.Now().Format(backupTimeFormat)
+	want := []call{{first, filename}, {second, filename}}
+	if len(calls) != len(want) || calls[0] != want[0] || calls[1] != want[1] {
+		t.Fatalf("calls = %q, want %q", calls, want)
+	}
+	for _, c := range calls {
+		if _, err := os.Stat(c.old); err != nil {
+			t.Errorf("backup %s: %v", c.old, err)
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	l := NewLogrotateT(testFilename(t), 10)
+	defer closeLog(t, l)
+
+	if err := l.Sync(); err != nil {
+		t.Fatalf("Sync before any write: %v", err)
+	}
+	write(t, l, "data\n")
+	if err := l.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+}
+
+func TestReopenAfterExternalMove(t *testing.T) {
+	filename := testFilename(t)
+	l := NewLogrotateT(filename, 10)
+	defer closeLog(t, l)
+
+	write(t, l, "before\n")
+	moved := filename + ".1"
+	if err := os.Rename(filename, moved); err != nil {
+		t.Fatal(err)
+	}
+	write(t, l, "still old\n")
+
+	if err := l.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.Size(); got != 0 {
+		t.Fatalf("size after Reopen = %d, want 0", got)
+	}
+	write(t, l, "after\n")
+
+	if got := readFile(t, moved); got != "before\nstill old\n" {
+		t.Fatalf("moved file = %q", got)
+	}
+	if got := readFile(t, filename); got != "after\n" {
+		t.Fatalf("file = %q", got)
+	}
+	movedInfo, _ := os.Stat(moved)
+	info, _ := os.Stat(filename)
+	if os.SameFile(movedInfo, info) {
+		t.Fatal("writes still go to the moved file")
+	}
+	if got := backupFiles(t, filepath.Dir(filename)); len(got) != 1 {
+		t.Fatalf("files = %q, Reopen must not make a backup", got)
+	}
+}
+
+func TestReopenKeepsSizeOfCopiedFile(t *testing.T) {
+	filename := testFilename(t)
+	l := NewLogrotateT(filename, 10)
+	defer closeLog(t, l)
+
+	write(t, l, "0123456789")
+	// logrotate(8) with copytruncate empties the file in place.
+	if err := os.Truncate(filename, 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.Size(); got != 4 {
+		t.Fatalf("size after Reopen = %d, want 4", got)
+	}
+}
+
+func TestReadFromRotatesAtMaxSize(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	l := newLogrotate(filename, WithMaxSizeBytes(100), WithClock(clock))
+	defer closeLog(t, l)
+
+	var data []byte
+	for i := 0; len(data) < 250; i++ {
+		data = append(data, byte('a'+i%26))
+	}
+	data = data[:250]
+
+	// hide WriterTo, so io.Copy calls ReadFrom.
+	n, err := io.Copy(l, struct{ io.Reader }{bytes.NewReader(data)})
+	if err != nil || n != int64(len(data)) {
+		t.Fatalf("Copy = %d, %v", n, err)
+	}
+
+	got := backupContents(t, filepath.Dir(filename))
+	if len(got) != 2 || got[0] != string(data[:100]) || got[1] != string(data[100:200]) {
+		t.Fatalf("backups = %q, want the first two 100 byte parts", got)
+	}
+	if got := readFile(t, filename); got != string(data[200:]) {
+		t.Fatalf("file = %q", got)
+	}
+}
+
+func TestReadFromFillsPartialFile(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(newTestClock()))
+	defer closeLog(t, l)
+
+	write(t, l, "1234")
+	if _, err := l.ReadFrom(strings.NewReader("56789abcdef")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := backupContents(t, filepath.Dir(filename)); len(got) != 1 || got[0] != "123456789a" {
+		t.Fatalf("backups = %q", got)
+	}
+	if got := readFile(t, filename); got != "bcdef" {
+		t.Fatalf("file = %q", got)
+	}
+}
+
+func TestReadFromMaxLines(t *testing.T) {
+	for _, delim := range []string{"\n", "\r\n", "<END>"} {
+		for _, oneByte := range []bool{false, true} {
+			filename := testFilename(t)
+			l := newLogrotate(filename, WithClock(newTestClock()))
+			l.MaxLines = 10
+			l.RecordDelimiter = []byte(delim)
+
+			var data strings.Builder
+			for i := 0; i < 25; i++ {
+				fmt.Fprintf(&data, "line %02d%s", i, delim)
+			}
+			var r io.Reader = strings.NewReader(data.String())
+			if oneByte {
+				r = iotest.OneByteReader(r)
+			}
+			// hide WriterTo, so io.Copy calls ReadFrom.
+			if _, err := io.Copy(l, struct{ io.Reader }{r}); err != nil {
+				t.Fatal(err)
+			}
+			closeLog(t, l)
+
+			backups := backupContents(t, filepath.Dir(filename))
+			if len(backups) != 2 {
+				t.Fatalf("delimiter %q: backups = %q, want two", delim, backups)
+			}
+			for _, b := range backups {
+				if n := strings.Count(b, delim); n != 10 || !strings.HasSuffix(b, delim) {
+					t.Errorf("delimiter %q, one byte reads %t: backup %q holds %d records, want 10", delim, oneByte, b, n)
+				}
+			}
+			if got := readFile(t, filename); got != data.String()[len(backups[0])+len(backups[1]):] {
+				t.Errorf("delimiter %q, one byte reads %t: file = %q", delim, oneByte, got)
+			}
+		}
+	}
+}
+
+// benchmarkData is copied into the file by the ReadFrom and Write
+// benchmarks.
+var benchmarkData = bytes.Repeat([]byte("0123456789abcdef"), 64<<10)
+
+func BenchmarkReadFrom(b *testing.B) {
+	l := newLogrotate(filepath.Join(b.TempDir(), "app.log"), WithMaxSizeBytes(64*Megabyte))
+	defer l.Close()
+
+	b.SetBytes(int64(len(benchmarkData)))
+	for i := 0; i < b.N; i++ {
+		if _, err := l.ReadFrom(bytes.NewReader(benchmarkData)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteChunks(b *testing.B) {
+	l := newLogrotate(filepath.Join(b.TempDir(), "app.log"), WithMaxSizeBytes(64*Megabyte))
+	defer l.Close()
+
+	// the 32 KiB chunks of io.Copy without ReadFrom.
+	b.SetBytes(int64(len(benchmarkData)))
+	for i := 0; i < b.N; i++ {
+		for p := benchmarkData; len(p) > 0; p = p[32<<10:] {
+			if _, err := l.Write(p[:32<<10]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestRotationRecreatesRemovedDir(t *testing.T) {
+	for _, fast := range []bool{false, true} {
+		dir := filepath.Join(t.TempDir(), "logs")
+		filename := filepath.Join(dir, "app.log")
+		l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(newTestClock()))
+		l.FastRotate = fast
+
+		write(t, l, "123456789\n")
+		if err := l.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		write(t, l, "lost\n")
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Rotate(); err != nil {
+			t.Fatalf("fast %t: Rotate after the directory was removed: %v", fast, err)
+		}
+		write(t, l, "again\n")
+		closeLog(t, l)
+
+		if got := readFile(t, filename); got != "again\n" {
+			t.Fatalf("fast %t: file = %q", fast, got)
+		}
+	}
+}
+
+func TestConcurrentWritesAcrossRotation(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compress=%t", compress), func(t *testing.T) {
+			l := newLogrotate(testFilename(t), WithMaxSizeBytes(100), WithCompress(compress))
+			l.AsyncCleanup = compress
+			testConcurrentWrites(t, l)
+		})
+	}
+}
+
+// testConcurrentWrites writes fixed-size records to l from many goroutines,
+// closes l and asserts that its files hold every record once and whole.
+func testConcurrentWrites(t *testing.T, l *Logrotate) {
+	t.Helper()
+	const writers, records = 8, 200
+	dir := filepath.Dir(l.Filename)
+
+	var wg sync.WaitGroup
+	for g := 0; g < writers; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < records; i++ {
+				rec := fmt.Sprintf("w%02d r%05d xxxx\n", g, i)
+				if _, err := l.Write([]byte(rec)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	closeLog(t, l)
+
+	seen := make(map[string]bool)
+	for _, name := range dirNames(t, dir) {
+		var content string
+		if strings.HasSuffix(name, ".gz") {
+			content = gunzip(t, filepath.Join(dir, name))
+		} else {
+			content = readFile(t, filepath.Join(dir, name))
+		}
+		if int64(len(content)) > l.MaxSize {
+			t.Fatalf("%s has %d bytes, want at most %d", name, len(content), l.MaxSize)
+		}
+		for _, rec := range strings.SplitAfter(content, "\n") {
+			if rec == "" {
+				continue
+			}
+			if len(rec) != 16 || seen[rec] {
+				t.Fatalf("%s: bad or repeated record %q", name, rec)
+			}
+			seen[rec] = true
+		}
+	}
+	if len(seen) != writers*records {
+		t.Fatalf("%d records, want %d", len(seen), writers*records)
+	}
+}
+
+func TestReset(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithMaxSizeBytes(100))
+	write(t, l, "old data\n")
+	if err := l.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filename); got != "" {
+		t.Fatalf("file after Reset = %q, want empty", got)
+	}
+	if files := backupFiles(t, filepath.Dir(filename)); len(files) != 0 {
+		t.Fatalf("backups = %q, want none", files)
+	}
+
+	write(t, l, "new\n")
+	closeLog(t, l)
+	if got := readFile(t, filename); got != "new\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
+
+func TestHeader(t *testing.T) {
+	filename := testFilename(t)
+	dir := filepath.Dir(filename)
+	l := newLogrotate(filename, WithMaxSizeBytes(20), WithClock(newTestClock()))
+	l.Header = []byte("a,b\n")
+	for i := 0; i < 3; i++ {
+		write(t, l, "123456789\n")
+	}
+	closeLog(t, l)
+
+	// reopening the non-empty file does not write the header again.
+	l = newLogrotate(filename, WithMaxSizeBytes(20))
+	l.Header = []byte("a,b\n")
+	write(t, l, "x\n")
+	closeLog(t, l)
+
+	files := backupFiles(t, dir)
+	if len(files) != 2 {
+		t.Fatalf("backups = %q, want 2", files)
+	}
+	for _, name := range files {
+		if got := readFile(t, filepath.Join(dir, name)); got != "a,b\n123456789\n" {
+			t.Errorf("%s = %q", name, got)
+		}
+	}
+	if got := readFile(t, filename); got != "a,b\n123456789\nx\n" {
+		t.Errorf("file = %q", got)
+	}
+}
+
+func TestFooter(t *testing.T) {
+	filename := testFilename(t)
+	dir := filepath.Dir(filename)
+	clock := newTestClock()
+	l := newLogrotate(filename, WithMaxSizeBytes(20), WithClock(clock))
+	l.Footer = []byte("END\n")
+	for i := 0; i < 5; i++ {
+		write(t, l, "123456789\n")
+		clock.Advance(time.Second)
+	}
+	closeLog(t, l)
+
+	if got, want := backupContents(t, dir), []string{"123456789\n123456789\nEND\n", "123456789\n123456789\nEND\n"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("backups = %q, want %q", got, want)
+	}
+	if got := readFile(t, filename); got != "123456789\nEND\n" {
+		t.Fatalf("file = %q", got)
+	}
+
+	// a Logrotate which never opened its file writes no footer.
+	calls := 0
+	other := filepath.Join(dir, "other.log")
+	l = newLogrotate(other)
+	l.FooterFunc = func() []byte { calls++; return []byte("END\n") }
+	closeLog(t, l)
+	if calls != 0 || exists(other) {
+		t.Fatalf("FooterFunc called %d times, file exists %t", calls, exists(other))
+	}
+}
+
+// countFS is the os fileSystem counting the writes to opened files.
+type countFS struct {
+	osFS
+	writes *int64
+}
+
+func (fs countFS) OpenFile(name string, flag int, perm os.FileMode) (file, error) {
+	f, err := fs.osFS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return countFile{f, fs.writes}, nil
+}
+
+type countFile struct {
+	file
+	writes *int64
+}
+
+func (f countFile) Write(p []byte) (int, error) {
+	atomic.AddInt64(f.writes, 1)
+	return f.file.Write(p)
+}
+
+func (f countFile) WriteString(s string) (int, error) {
+	atomic.AddInt64(f.writes, 1)
+	return f.file.WriteString(s)
+}
+
+func TestBufferWrittenOnClose(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithBufferSize(4096))
+	for i := 0; i < 100; i++ {
+		write(t, l, "line\n")
+	}
+	if got := readFile(t, filename); got != "" {
+		t.Fatalf("file before Close = %q, want the data still buffered", got)
+	}
+	closeLog(t, l)
+	if got := readFile(t, filename); got != strings.Repeat("line\n", 100) {
+		t.Fatalf("file after Close has %d bytes, want %d", len(got), 500)
+	}
+}
+
+func TestBufferCountsTowardMaxSize(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithBufferSize(4096), WithClock(newTestClock()))
+	write(t, l, "12345\n")
+	write(t, l, "1234\n")
+	// the buffered first write counts, so the second goes to a new file.
+	if got := l.Size(); got != 5 {
+		t.Fatalf("Size = %d, want 5", got)
+	}
+	closeLog(t, l)
+
+	files := backupFiles(t, filepath.Dir(filename))
+	if len(files) != 1 {
+		t.Fatalf("backups = %q, want 1", files)
+	}
+	if got := readFile(t, filepath.Join(filepath.Dir(filename), files[0])); got != "12345\n" {
+		t.Fatalf("backup = %q", got)
+	}
+	if got := readFile(t, filename); got != "1234\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
+
+func BenchmarkSmallWrites(b *testing.B) {
+	line := []byte("a short log line of about sixty bytes, like most of them\n")
+	for _, size := range []int{0, 64 << 10} {
+		b.Run(fmt.Sprintf("buffer=%d", size), func(b *testing.B) {
+			var writes int64
+			l := newLogrotate(filepath.Join(b.TempDir(), "app.log"), WithMaxSizeBytes(64*Megabyte), WithBufferSize(size))
+			l.fs = countFS{writes: &writes}
+			defer l.Close()
+
+			b.SetBytes(int64(len(line)))
+			for i := 0; i < b.N; i++ {
+				if _, err := l.Write(line); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(atomic.LoadInt64(&writes))/float64(b.N), "writes/op")
+		})
+	}
+}
+
+// failRenameFS is the os fileSystem failing the next n renames.
+type failRenameFS struct {
+	osFS
+	n *int
+}
+
+func (fs failRenameFS) Rename(oldpath, newpath string) error {
+	if *fs.n > 0 {
+		*fs.n--
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrPermission}
+	}
+	return fs.osFS.Rename(oldpath, newpath)
+}
+
+// staleFS is the os fileSystem which creates a file at the old name after
+// each rename, like another process writing to it right after the move.
+type staleFS struct {
+	osFS
+}
+
+func (fs staleFS) Rename(oldpath, newpath string) error {
+	if err := fs.osFS.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+	return os.WriteFile(oldpath, []byte("stale\n"), 0644)
+}
+
+func TestNewFileTruncate(t *testing.T) {
+	for _, tt := range []struct {
+		truncate bool
+		want     string
+	}{
+		{false, "stale\nnew\n"},
+		{true, "new\n"},
+	} {
+		filename := testFilename(t)
+		l := newLogrotate(filename, WithClock(newTestClock()))
+		l.NewFileTruncate = tt.truncate
+		l.fs = staleFS{}
+		write(t, l, "old\n")
+		if err := l.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		write(t, l, "new\n")
+		closeLog(t, l)
+
+		if got := readFile(t, filename); got != tt.want {
+			t.Errorf("truncate %t: file = %q, want %q", tt.truncate, got, tt.want)
+		}
+		if got := backupContents(t, filepath.Dir(filename)); len(got) != 1 || got[0] != "old\n" {
+			t.Errorf("truncate %t: backups = %q", tt.truncate, got)
+		}
+	}
+}
+
+func TestWriteAfterFailedRotation(t *testing.T) {
+	for _, fast := range []bool{false, true} {
+		filename := testFilename(t)
+		fails := 1
+		l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(newTestClock()))
+		l.fs = failRenameFS{n: &fails}
+		l.FastRotate = fast
+		write(t, l, "123456789\n")
+
+		if _, err := l.Write([]byte("abc\n")); !errors.Is(err, os.ErrPermission) {
+			t.Fatalf("FastRotate %t: Write = %v, want the rename error", fast, err)
+		}
+		write(t, l, "def\n")
+		closeLog(t, l)
+
+		files := backupFiles(t, filepath.Dir(filename))
+		if len(files) != 1 {
+			t.Fatalf("FastRotate %t: backups = %q, want 1", fast, files)
+		}
+		if got := readFile(t, filepath.Join(filepath.Dir(filename), files[0])); got != "123456789\n" {
+			t.Fatalf("FastRotate %t: backup = %q", fast, got)
+		}
+		if got := readFile(t, filename); got != "def\n" {
+			t.Fatalf("FastRotate %t: file = %q", fast, got)
+		}
+	}
+}
+
+func TestWriteStringRotation(t *testing.T) {
+	lines := []string{"12345\n", "1234\n", "123456789\n", "ab\n"}
+	var want []string
+	for i, ws := range []bool{false, true} {
+		filename := testFilename(t)
+		dir := filepath.Dir(filename)
+		l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(newTestClock()))
+		for _, s := range lines {
+			var err error
+			if ws {
+				_, err = l.WriteString(s)
+			} else {
+				_, err = l.Write([]byte(s))
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		closeLog(t, l)
+
+		got := append(backupContents(t, dir), readFile(t, filename))
+		if i == 0 {
+			want = got
+			continue
+		}
+		if strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Fatalf("WriteString files = %q, Write files = %q", got, want)
+		}
+	}
+}
+
+var benchmarkLine = "a short log line of about sixty bytes, like most of them\n"
+
+func BenchmarkWriteString(b *testing.B) {
+	l := newLogrotate(filepath.Join(b.TempDir(), "app.log"), WithMaxSizeBytes(64*Megabyte), WithBufferSize(64<<10))
+	defer l.Close()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := l.WriteString(benchmarkLine); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteBytesOfString(b *testing.B) {
+	l := newLogrotate(filepath.Join(b.TempDir(), "app.log"), WithMaxSizeBytes(64*Megabyte), WithBufferSize(64<<10))
+	defer l.Close()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := l.Write([]byte(benchmarkLine)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMegabyteBase(t *testing.T) {
+	for _, opt := range []struct {
+		opt  Option
+		base int64
+	}{
+		{WithMaxSize(1), 1024 * 1024},
+		{WithMaxSizeBytes(DecimalMegabyte), 1000 * 1000},
+	} {
+		filename := testFilename(t)
+		l := newLogrotate(filename, opt.opt, WithClock(newTestClock()))
+		if l.MaxSize != opt.base {
+			t.Fatalf("MaxSize = %d, want %d", l.MaxSize, opt.base)
+		}
+
+		write(t, l, strings.Repeat("x", int(opt.base)))
+		if files := backupFiles(t, filepath.Dir(filename)); len(files) != 0 {
+			t.Fatalf("base %d: backups after %d bytes = %q, want none", opt.base, opt.base, files)
+		}
+		write(t, l, "y")
+		closeLog(t, l)
+		if files := backupFiles(t, filepath.Dir(filename)); len(files) != 1 {
+			t.Fatalf("base %d: backups after %d bytes = %q, want 1", opt.base, opt.base+1, files)
+		}
+	}
+}
+
+func TestStartupMode(t *testing.T) {
+	for _, tt := range []struct {
+		mode    StartupMode
+		file    string
+		backups []string
+	}{
+		{AppendMode, "old\nnew\n", nil},
+		{TruncateMode, "new\n", nil},
+		{RotateExistingMode, "new\n", []string{"old\n"}},
+	} {
+		filename := testFilename(t)
+		if err := os.WriteFile(filename, []byte("old\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		l := newLogrotate(filename)
+		l.StartupMode = tt.mode
+		write(t, l, "new\n")
+
+		if got := readFile(t, filename); got != tt.file {
+			t.Errorf("mode %d: file = %q, want %q", tt.mode, got, tt.file)
+		}
+		if got := backupContents(t, filepath.Dir(filename)); strings.Join(got, "|") != strings.Join(tt.backups, "|") {
+			t.Errorf("mode %d: backups = %q, want %q", tt.mode, got, tt.backups)
+		}
+
+		// the mode only applies to the first open.
+		closeLog(t, l)
+		write(t, l, "more\n")
+		closeLog(t, l)
+		if got := readFile(t, filename); got != tt.file+"more\n" {
+			t.Errorf("mode %d: file after reopen = %q", tt.mode, got)
+		}
+	}
+}
+
+func TestBareFilename(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	l := newLogrotate("test.log", WithMaxSizeBytes(10), WithClock(newTestClock()))
+	write(t, l, "123456789\n")
+	write(t, l, "abc\n")
+	closeLog(t, l)
+
+	if got := readFile(t, filepath.Join(dir, "test.log")); got != "abc\n" {
+		t.Fatalf("file = %q", got)
+	}
+	if names := dirNames(t, dir); len(names) != 2 {
+		t.Fatalf("files in cwd = %q, want the file and a backup", names)
+	}
+}
+
+func TestMaxLines(t *testing.T) {
+	for _, tt := range []struct {
+		countWrites bool
+		writes      []string
+		backups     []string
+		file        string
+	}{
+		{false, []string{"a\nb\n", "c\n", "d\ne\n"}, []string{"a\nb\nc\n"}, "d\ne\n"},
+		{true, []string{"a\nb\n", "c\n", "d\ne\n", "f"}, []string{"a\nb\nc\nd\ne\n"}, "f"},
+	} {
+		filename := testFilename(t)
+		l := newLogrotate(filename, WithClock(newTestClock()))
+		l.MaxLines = 3
+		l.CountWrites = tt.countWrites
+		for _, s := range tt.writes {
+			write(t, l, s)
+		}
+		closeLog(t, l)
+
+		if got := backupContents(t, filepath.Dir(filename)); strings.Join(got, "|") != strings.Join(tt.backups, "|") {
+			t.Errorf("CountWrites %t: backups = %q, want %q", tt.countWrites, got, tt.backups)
+		}
+		if got := readFile(t, filename); got != tt.file {
+			t.Errorf("CountWrites %t: file = %q, want %q", tt.countWrites, got, tt.file)
+		}
+	}
+}
+
+// testDefaultSize fills l to the default size, asserts that a byte more
+// rotates the file and closes l.
+func testDefaultSize(t *testing.T, l *Logrotate) {
+	t.Helper()
+	write(t, l, strings.Repeat("x", int(defaultSize*Megabyte)))
+	if files := backupFiles(t, filepath.Dir(l.Filename)); len(files) != 0 {
+		t.Fatalf("backups at the default size = %q, want none", files)
+	}
+	write(t, l, "y")
+	closeLog(t, l)
+	if files := backupFiles(t, filepath.Dir(l.Filename)); len(files) != 1 {
+		t.Fatalf("backups past the default size = %q, want 1", files)
+	}
+}
+
+func TestZeroMaxSizeUsesDefault(t *testing.T) {
+	testDefaultSize(t, &Logrotate{Filename: testFilename(t)})
+}
+
+func TestDefaultSize(t *testing.T) {
+	if defaultSize*Megabyte != 10*1024*1024 {
+		t.Fatalf("default size = %d, want the documented 10 megabytes", defaultSize*Megabyte)
+	}
+	for _, size := range []int64{0, -1} {
+		testDefaultSize(t, NewLogrotateT(testFilename(t), size))
+	}
+}
+
+func TestWatchActive(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename)
+	l.WatchActive = true
+	defer closeLog(t, l)
+
+	write(t, l, "lost\n")
+	if err := os.Remove(filename); err != nil {
+		t.Fatal(err)
+	}
+	write(t, l, "new\n")
+	if got := readFile(t, filename); got != "new\n" {
+		t.Fatalf("file = %q, want a new file with the new data", got)
+	}
+}
+
+func TestWatchActiveReadFrom(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename)
+	l.WatchActive = true
+	defer closeLog(t, l)
+
+	write(t, l, "lost\n")
+	if err := os.Remove(filename); err != nil {
+		t.Fatal(err)
+	}
+	// hide WriterTo, so io.Copy calls ReadFrom.
+	if _, err := io.Copy(l, struct{ io.Reader }{strings.NewReader("new\n")}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filename); got != "new\n" {
+		t.Fatalf("file = %q, want a new file with the copied data", got)
+	}
+}
+
+func TestNewLogrotateT(t *testing.T) {
+	filename := testFilename(t)
+	l := NewLogrotateT(filename, 1)
+	defer closeLog(t, l)
+
+	write(t, l, "abc\n")
+	if got := l.Size(); got != 4 {
+		t.Fatalf("Size() = %d, want 4", got)
+	}
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.Size(); got != 0 {
+		t.Fatalf("Size() after Rotate = %d, want 0", got)
+	}
+}
+
+func TestNoEmptyBackup(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithMaxSizeBytes(4), WithClock(newTestClock()))
+	defer closeLog(t, l)
+
+	write(t, l, "longer than four\n")
+	if files := backupFiles(t, filepath.Dir(filename)); len(files) != 0 {
+		t.Fatalf("backups = %q, want none", files)
+	}
+	if got := readFile(t, filename); got != "longer than four\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
+
+func TestWriteSyncer(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename)
+	defer closeLog(t, l)
+
+	var ws WriteSyncer = l
+	if _, err := ws.Write([]byte("abc\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filename); got != "abc\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
+
+func TestMaxSizeBoundary(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(newTestClock()))
+	defer closeLog(t, l)
+
+	write(t, l, "1234")
+	write(t, l, "567890")
+	if got := l.Size(); got != 10 {
+		t.Fatalf("Size() = %d, want exactly MaxSize", got)
+	}
+	if files := backupFiles(t, filepath.Dir(filename)); len(files) != 0 {
+		t.Fatalf("backups at exactly MaxSize = %q, want none", files)
+	}
+
+	write(t, l, "x")
+	if got := backupContents(t, filepath.Dir(filename)); len(got) != 1 || got[0] != "1234567890" {
+		t.Fatalf("backups = %q, want the full file", got)
+	}
+	if got := readFile(t, filename); got != "x" {
+		t.Fatalf("file = %q, want the byte past MaxSize", got)
+	}
+}
+
+func TestMaxSizeNeverExceeded(t *testing.T) {
+	filename := testFilename(t)
+	dir := filepath.Dir(filename)
+	l := newLogrotate(filename, WithMaxSizeBytes(10))
+	defer closeLog(t, l)
+
+	total := 0
+	for i := 0; i < 200; i++ {
+		n := i%10 + 1
+		write(t, l, strings.Repeat("x", n))
+		total += n
+
+		for _, name := range dirNames(t, dir) {
+			info, err := os.Stat(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size() > 10 {
+				t.Fatalf("%s has %d bytes after write %d", name, info.Size(), i)
+			}
+		}
+	}
+
+	got := 0
+	for _, s := range append(backupContents(t, dir), readFile(t, filename)) {
+		got += len(s)
+	}
+	if got != total {
+		t.Fatalf("%d bytes on disk, want %d", got, total)
+	}
+}
+
+func TestMaxOpenDuration(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	l := newLogrotate(filename, WithClock(clock))
+	l.MaxOpenDuration = 90 * time.Minute
+	defer closeLog(t, l)
+
+	write(t, l, "one\n")
+	clock.Advance(time.Hour)
+	write(t, l, "two\n")
+	if files := backupFiles(t, filepath.Dir(filename)); len(files) != 0 {
+		t.Fatalf("backups after 1h = %q, want none", files)
+	}
+
+	clock.Advance(time.Hour)
+	write(t, l, "three\n")
+	if got := backupContents(t, filepath.Dir(filename)); len(got) != 1 || got[0] != "one\ntwo\n" {
+		t.Fatalf("backups after 2h = %q", got)
+	}
+	if got := readFile(t, filename); got != "three\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
+
+func TestSetMaxSize(t *testing.T) {
+	filename := testFilename(t)
+	l := NewLogrotateT(filename, 2)
+	defer closeLog(t, l)
+
+	write(t, l, strings.Repeat("x", int(3*Megabyte/2)))
+	if err := l.SetMaxSize(1); err != nil {
+		t.Fatal(err)
+	}
+	write(t, l, "y")
+	if files := backupFiles(t, filepath.Dir(filename)); len(files) != 1 {
+		t.Fatalf("backups = %q, want 1 after lowering MaxSize", files)
+	}
+	if got := readFile(t, filename); got != "y" {
+		t.Fatalf("file = %q", got)
+	}
+
+	for _, size := range []int64{0, -1, 1 << 50} {
+		if err := l.SetMaxSize(size); err == nil {
+			t.Errorf("SetMaxSize(%d) succeeded", size)
+		}
+	}
+	if l.MaxSize != Megabyte {
+		t.Fatalf("MaxSize = %d after invalid sizes", l.MaxSize)
+	}
+}
+
+func TestSetFilename(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithClock(newTestClock()))
+	defer closeLog(t, l)
+	write(t, l, "old\n")
+
+	// the file moves to a directory which is created.
+	moved := filepath.Join(filepath.Dir(filename), "new", "app.log")
+	if err := l.SetFilename(moved, true); err != nil {
+		t.Fatal(err)
+	}
+	write(t, l, "new\n")
+	if exists(filename) {
+		t.Fatalf("%s still exists after the move", filename)
+	}
+	if got := readFile(t, moved); got != "old\nnew\n" {
+		t.Fatalf("moved file = %q", got)
+	}
+	if got := l.Size(); got != 8 {
+		t.Fatalf("Size() = %d, want 8", got)
+	}
+
+	// without move the file stays and the new one starts empty.
+	other := filepath.Join(filepath.Dir(filename), "other.log")
+	if err := l.SetFilename(other, false); err != nil {
+		t.Fatal(err)
+	}
+	write(t, l, "other\n")
+	if got := readFile(t, moved); got != "old\nnew\n" {
+		t.Fatalf("left file = %q", got)
+	}
+	if got := readFile(t, other); got != "other\n" {
+		t.Fatalf("new file = %q", got)
+	}
+
+	if err := l.SetFilename(filepath.Join(filepath.Dir(filename), "new", "app.log"), true); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("SetFilename to an existing file = %v, want os.ErrExist", err)
+	}
+}
+
+func TestSettersKeepContent(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithMaxSizeBytes(1000), WithClock(newTestClock()))
+	l.MaxLines = 3
+	defer closeLog(t, l)
+	write(t, l, "one\n")
+	write(t, l, "two\n")
+
+	// SetMaxSize rotates nothing by itself.
+	if err := l.SetMaxSize(1); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.Size(); got != 8 {
+		t.Fatalf("Size() = %d after SetMaxSize, want 8", got)
+	}
+
+	// the current Filename is not reopened.
+	f := l.file
+	if err := l.SetFilename(filename, true); err != nil {
+		t.Fatal(err)
+	}
+	if l.file != f {
+		t.Fatal("SetFilename of the current file reopened it")
+	}
+
+	// a moved file keeps its counters.
+	moved := filepath.Join(filepath.Dir(filename), "moved.log")
+	if err := l.SetFilename(moved, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.Size(); got != 8 || l.lines != 2 {
+		t.Fatalf("Size() = %d, lines %d after the move, want 8 and 2", got, l.lines)
+	}
+	if got := readFile(t, moved); got != "one\ntwo\n" {
+		t.Fatalf("moved file = %q", got)
+	}
+	write(t, l, "three\n")
+	write(t, l, "four\n")
+	if got := l.Rotations(); got != 1 {
+		t.Fatalf("Rotations() = %d, want 1 by MaxLines", got)
+	}
+	backups, err := filepath.Glob(moved + ".*")
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("backups = %q, %v, want 1", backups, err)
+	}
+	if got := readFile(t, backups[0]); got != "one\ntwo\nthree\n" {
+		t.Fatalf("backup = %q", got)
+	}
+}
+
+// TestSetFilenameWithMill switches the file while the mill still works on
+// backups of the old one.
+func TestSetFilenameWithMill(t *testing.T) {
+	dir := t.TempDir()
+	l := newLogrotate(filepath.Join(dir, "a.log"), WithMaxSizeBytes(10), WithMaxBackups(2), WithCompress(true))
+	l.AsyncCleanup = true
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			if _, err := l.Write([]byte("123456789\n")); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		if err := l.SetFilename(filepath.Join(dir, fmt.Sprintf("%c.log", 'a'+i%2)), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+	closeLog(t, l)
+}
+
+func TestDisableSizeRotation(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(newTestClock()))
+	l.DisableSizeRotation = true
+	defer closeLog(t, l)
+
+	for i := 0; i < 5; i++ {
+		write(t, l, "123456789\n")
+	}
+	if _, err := l.ReadFrom(strings.NewReader(strings.Repeat("x", 100))); err != nil {
+		t.Fatal(err)
+	}
+	if files := backupFiles(t, filepath.Dir(filename)); len(files) != 0 {
+		t.Fatalf("backups = %q, want none", files)
+	}
+	if got := l.Size(); got != 150 {
+		t.Fatalf("Size() = %d, want 150", got)
+	}
+
+	// a manual rotation still happens.
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := backupContents(t, filepath.Dir(filename)); len(got) != 1 || len(got[0]) != 150 {
+		t.Fatalf("backups = %q, want one of 150 bytes", got)
+	}
+}
+
+func TestRotateIfNeeded(t *testing.T) {
+	filename := testFilename(t)
+	dir := filepath.Dir(filename)
+	clock := newTestClock()
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(clock))
+	defer closeLog(t, l)
+
+	rotateIfNeeded := func(want bool) {
+		t.Helper()
+		if ok, err := l.RotateIfNeeded(); ok != want || err != nil {
+			t.Fatalf("RotateIfNeeded = %t, %v, want %t", ok, err, want)
+		}
+	}
+	rotateIfNeeded(false)
+	write(t, l, "12345")
+	rotateIfNeeded(false)
+	write(t, l, "6789\n")
+	rotateIfNeeded(true)
+	if got := backupContents(t, dir); len(got) != 1 || got[0] != "123456789\n" {
+		t.Fatalf("backups = %q", got)
+	}
+	if got := l.Size(); got != 0 {
+		t.Fatalf("Size() = %d, want 0", got)
+	}
+
+	// by time and by ShouldRotate.
+	l.RotationInterval = time.Hour
+	write(t, l, "x\n")
+	rotateIfNeeded(false)
+	clock.Advance(time.Hour)
+	rotateIfNeeded(true)
+	write(t, l, "y\n")
+	l.ShouldRotate = func(l *Logrotate, p []byte) bool { return p == nil }
+	rotateIfNeeded(true)
+	if n := len(backupFiles(t, dir)); n != 3 {
+		t.Fatalf("%d backups, want 3", n)
+	}
+}
+
+func TestMinRotateInterval(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(clock))
+	l.MinRotateInterval = time.Minute
+	defer closeLog(t, l)
+
+	for i := 0; i < 10; i++ {
+		write(t, l, "123456789\n")
+		clock.Advance(time.Second)
+	}
+	if n := len(backupFiles(t, filepath.Dir(filename))); n != 1 {
+		t.Fatalf("%d rotations within the interval, want 1", n)
+	}
+
+	clock.Advance(time.Minute)
+	write(t, l, "123456789\n")
+	if n := len(backupFiles(t, filepath.Dir(filename))); n != 2 {
+		t.Fatalf("%d rotations after the interval, want 2", n)
+	}
+}
+
+func TestNotWriterAt(t *testing.T) {
+	var w any = &Logrotate{}
+	if _, ok := w.(io.WriterAt); ok {
+		t.Fatal("*Logrotate implements io.WriterAt, but writes always append")
+	}
+}
+
+func TestSyncOnRotate(t *testing.T) {
+	filename := testFilename(t)
+	dir := filepath.Dir(filename)
+	l := newLogrotate(filename, WithMaxSizeBytes(50))
+	l.SyncOnRotate = true
+	for i := 0; i < 100; i++ {
+		write(t, l, fmt.Sprintf("line %d\n", i))
+	}
+	closeLog(t, l)
+
+	backups := backupContents(t, dir)
+	if len(backups) < 10 {
+		t.Fatalf("%d backups, want many rotations", len(backups))
+	}
+	for i, b := range backups {
+		if !strings.HasSuffix(b, "\n") {
+			t.Fatalf("backup %d ends with a partial line: %q", i, b)
+		}
+	}
+}
+
+func TestFlush(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithBufferSize(4096))
+	defer closeLog(t, l)
+
+	write(t, l, "one\n")
+	if err := l.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filename); got != "one\n" {
+		t.Fatalf("file after Flush = %q", got)
+	}
+
+	write(t, l, "two\n")
+	if err := l.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filename); got != "one\ntwo\n" {
+		t.Fatalf("file after Sync = %q", got)
+	}
+
+	// both do nothing harmful without a buffer.
+	plain := newLogrotate(testFilename(t))
+	defer closeLog(t, plain)
+	if err := plain.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	write(t, plain, "abc\n")
+	if err := plain.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.Sync(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFastRotateConcurrentWrites(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compress=%t", compress), func(t *testing.T) {
+			l := newLogrotate(testFilename(t), WithMaxSizeBytes(100), WithCompress(compress))
+			l.AsyncCleanup = compress
+			l.FastRotate = true
+			testConcurrentWrites(t, l)
+		})
+	}
+}
+
+func BenchmarkRotate(b *testing.B) {
+	for _, fast := range []bool{false, true} {
+		b.Run(fmt.Sprintf("fast=%t", fast), func(b *testing.B) {
+			l := newLogrotate(filepath.Join(b.TempDir(), "app.log"), WithMaxBackups(1))
+			l.FastRotate = fast
+			defer l.Close()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := l.Write(benchmarkData[:1024]); err != nil {
+					b.Fatal(err)
+				}
+				if err := l.Rotate(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordDelimiter(t *testing.T) {
+	for _, delim := range []string{"\x00", "\r\n", "<END>"} {
+		filename := testFilename(t)
+		l := newLogrotate(filename, WithClock(newTestClock()))
+		l.MaxLines = 3
+		l.RecordDelimiter = []byte(delim)
+
+		// the records and delimiters are split across writes.
+		data := "a" + delim + "\nb" + delim + "c" + delim
+		for i := 0; i < len(data); i++ {
+			write(t, l, data[i:i+1])
+		}
+		write(t, l, "d"+delim)
+		closeLog(t, l)
+
+		if got := backupContents(t, filepath.Dir(filename)); len(got) != 1 || got[0] != data {
+			t.Errorf("delimiter %q: backups = %q, want %q", delim, got, data)
+		}
+		if got := readFile(t, filename); got != "d"+delim {
+			t.Errorf("delimiter %q: file = %q", delim, got)
+		}
+	}
+}
+
+func TestReadTail(t *testing.T) {
+	for _, rw := range []bool{false, true} {
+		l := newLogrotate(testFilename(t), WithBufferSize(4096))
+		l.OpenReadWrite = rw
+		write(t, l, "first line\n")
+		if err := l.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		write(t, l, "second line\n")
+
+		for _, tt := range []struct {
+			n    int
+			want string
+		}{
+			{0, ""},
+			{5, "line\n"},
+			{12, "second line\n"},
+			{15, "ne\nsecond line\n"},
+			{100, "first line\nsecond line\n"},
+		} {
+			got, err := l.ReadTail(tt.n)
+			if err != nil || string(got) != tt.want {
+				t.Errorf("OpenReadWrite %t: ReadTail(%d) = %q, %v, want %q", rw, tt.n, got, err, tt.want)
+			}
+		}
+		if got, err := l.ReadTail(-1); err == nil {
+			t.Errorf("OpenReadWrite %t: ReadTail(-1) = %q, want an error", rw, got)
+		}
+		closeLog(t, l)
+	}
+}
+
+func TestShouldRotate(t *testing.T) {
+	filename := testFilename(t)
+	var sizes []int64
+	l := newLogrotate(filename, WithClock(newTestClock()))
+	l.ShouldRotate = func(cur *Logrotate, p []byte) bool {
+		sizes = append(sizes, cur.SizeLocked())
+		return bytes.Contains(p, []byte("--cut--"))
+	}
+	defer closeLog(t, l)
+
+	for _, s := range []string{"one\n", "two\n", "--cut-- three\n", "four\n"} {
+		write(t, l, s)
+	}
+	if got := backupContents(t, filepath.Dir(filename)); len(got) != 1 || got[0] != "one\ntwo\n" {
+		t.Fatalf("backups = %q", got)
+	}
+	if got := readFile(t, filename); got != "--cut-- three\nfour\n" {
+		t.Fatalf("file = %q", got)
+	}
+	if want := []int64{0, 4, 8, 14}; !reflect.DeepEqual(sizes, want) {
+		t.Fatalf("sizes seen = %v, want %v", sizes, want)
+	}
+}
+
+func TestShouldRotateReadFrom(t *testing.T) {
+	filename := testFilename(t)
+	calls := 0
+	l := newLogrotate(filename, WithClock(newTestClock()))
+	l.ShouldRotate = func(cur *Logrotate, p []byte) bool {
+		calls++
+		return bytes.HasPrefix(p, []byte("--cut--"))
+	}
+	defer closeLog(t, l)
+
+	write(t, l, "one\n")
+	// hide WriterTo, so io.Copy calls ReadFrom.
+	if _, err := io.Copy(l, struct{ io.Reader }{strings.NewReader("--cut-- two\n")}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatalf("ShouldRotate called %d times, want 2", calls)
+	}
+	if got := backupContents(t, filepath.Dir(filename)); len(got) != 1 || got[0] != "one\n" {
+		t.Fatalf("backups = %q", got)
+	}
+	if got := readFile(t, filename); got != "--cut-- two\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
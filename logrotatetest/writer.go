@@ -0,0 +1,123 @@
+// Package logrotatetest provides an in-memory logrotate.Rotator for tests
+// of code writing logs.
+package logrotatetest
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/TermiusOne/logrotate"
+)
+
+var _ logrotate.Rotator = (*Writer)(nil)
+
+// Writer is a logrotate.Rotator which keeps the current file and the
+// backups in memory and records rotations, without touching the disk.
+type Writer struct {
+	// Filename is returned by CurrentFile.
+	Filename string
+
+	// MaxSize rotates the file before a write which would make it larger,
+	// like logrotate.Logrotate does. Zero disables it.
+	MaxSize int64
+
+	mu        sync.Mutex
+	cur       bytes.Buffer
+	backups   [][]byte
+	writes    int
+	rotations uint64
+	closed    bool
+}
+
+// Write appends p to the current file, rotating it first if MaxSize is
+// reached.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.MaxSize > 0 && w.cur.Len() > 0 && int64(w.cur.Len()+len(p)) > w.MaxSize {
+		w.rotate()
+	}
+
+	w.closed = false
+	w.writes++
+	return w.cur.Write(p)
+}
+
+// Close marks the writer closed, a later Write opens it again.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	return nil
+}
+
+// Rotate moves the current file to a backup.
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.rotate()
+	return nil
+}
+
+func (w *Writer) rotate() {
+	w.backups = append(w.backups, bytes.Clone(w.cur.Bytes()))
+	w.cur.Reset()
+	w.rotations++
+}
+
+// Size returns the size of the current file.
+func (w *Writer) Size() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return int64(w.cur.Len())
+}
+
+// Rotations returns the number of rotations.
+func (w *Writer) Rotations() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rotations
+}
+
+// CurrentFile returns Filename.
+func (w *Writer) CurrentFile() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.Filename
+}
+
+// Current returns a copy of the data of the current file.
+func (w *Writer) Current() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return bytes.Clone(w.cur.Bytes())
+}
+
+// Backups returns copies of the rotated files from oldest to newest.
+func (w *Writer) Backups() [][]byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	list := make([][]byte, len(w.backups))
+	for i, b := range w.backups {
+		list[i] = bytes.Clone(b)
+	}
+	return list
+}
+
+// Writes returns the number of Write calls.
+func (w *Writer) Writes() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.writes
+}
+
+// Closed reports whether Close was called after the last Write.
+func (w *Writer) Closed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closed
+}
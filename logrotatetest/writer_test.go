@@ -0,0 +1,57 @@
+package logrotatetest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/TermiusOne/logrotate"
+)
+
+// logBatches is code under test, it writes the batches and rotates the log
+// after each one.
+func logBatches(r logrotate.Rotator, batches ...[]string) error {
+	for _, batch := range batches {
+		for _, line := range batch {
+			if _, err := fmt.Fprintln(r, line); err != nil {
+				return err
+			}
+		}
+		if err := r.Rotate(); err != nil {
+			return err
+		}
+	}
+	return r.Close()
+}
+
+func TestWriterRecordsRotations(t *testing.T) {
+	w := &Writer{Filename: "app.log"}
+	if err := logBatches(w, []string{"a", "b"}, []string{"c"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := w.Rotations(); got != 2 {
+		t.Fatalf("Rotations() = %d, want 2", got)
+	}
+	backups := w.Backups()
+	if len(backups) != 2 || string(backups[0]) != "a\nb\n" || string(backups[1]) != "c\n" {
+		t.Fatalf("Backups() = %q", backups)
+	}
+	if w.Writes() != 3 || !w.Closed() || w.Size() != 0 {
+		t.Fatalf("Writes() = %d, Closed() = %t, Size() = %d", w.Writes(), w.Closed(), w.Size())
+	}
+}
+
+func TestWriterMaxSize(t *testing.T) {
+	w := &Writer{MaxSize: 4}
+	for _, s := range []string{"ab", "cd", "e"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := w.Backups(); len(got) != 1 || string(got[0]) != "abcd" {
+		t.Fatalf("Backups() = %q, want the full file", got)
+	}
+	if got := string(w.Current()); got != "e" {
+		t.Fatalf("Current() = %q", got)
+	}
+}
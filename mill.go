@@ -0,0 +1,129 @@
+package logrotate
+
+import (
+	"context"
+	"errors"
+)
+
+// millQueue is the number of rotations queued for the mill before
+// rotation waits for it.
+const millQueue = 64
+
+// startMill starts the background goroutine compressing and removing
+// backups with AsyncCleanup. It must be called with mu held.
+func (l *Logrotate) startMill() {
+	if l.millCh != nil {
+		return
+	}
+
+	l.millCh = make(chan string, millQueue)
+	l.millDone = make(chan struct{})
+	go l.millRun(l.millCh, l.millDone)
+}
+
+// millRun finishes rotated backups until ch is closed. An empty name only
+// requests the retention.
+func (l *Logrotate) millRun(ch <-chan string, done chan<- struct{}) {
+	defer close(done)
+
+	for name := range ch {
+		if name != "" {
+			l.millError(l.finishBackup(name))
+			l.setQueued(name, false)
+		}
+
+		if l.AsyncCleanup {
+			l.millError(l.millRetention())
+		}
+
+		l.millPending.Done()
+	}
+}
+
+// millRetention applies the retention for the mill.
+func (l *Logrotate) millRetention() error {
+	return l.removeBackups()
+}
+
+// queueMill hands the backup name to the mill. It must be called with mu
+// held.
+func (l *Logrotate) queueMill(name string) {
+	l.startMill()
+	if name != "" {
+		l.setQueued(name, true)
+	}
+	l.millPending.Add(1)
+	l.millCh <- name
+}
+
+// setQueued marks the backup name as waiting for the mill, so the retention
+// does not remove it while it is compressed.
+func (l *Logrotate) setQueued(name string, queued bool) {
+	l.cacheMu.Lock()
+	defer l.cacheMu.Unlock()
+
+	if !queued {
+		delete(l.queued, name)
+		return
+	}
+	if l.queued == nil {
+		l.queued = make(map[string]bool)
+	}
+	l.queued[name] = true
+}
+
+// isQueued reports whether the backup path waits for the mill.
+func (l *Logrotate) isQueued(path string) bool {
+	l.cacheMu.Lock()
+	defer l.cacheMu.Unlock()
+	return l.queued[path]
+}
+
+// stopMill waits until the mill has handled all backups and exits, or ctx
+// is done. Concurrent calls all wait for the same mill. It must be called
+// without mu held.
+func (l *Logrotate) stopMill(ctx context.Context) error {
+	l.mu.Lock()
+	if l.millCh != nil {
+		close(l.millCh)
+		l.millCh = nil
+	}
+	done := l.millDone
+	l.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	if l.millDone == done {
+		l.millDone = nil
+	}
+	l.mu.Unlock()
+	return nil
+}
+
+// millError keeps err of the mill for ErrorHandler until the next unlock,
+// as the mill runs without mu. The first error but ErrOverTotalSize is also
+// returned by Close.
+func (l *Logrotate) millError(err error) {
+	if err == nil {
+		return
+	}
+	l.diagf("error: %v", err)
+
+	l.errMu.Lock()
+	defer l.errMu.Unlock()
+	if l.ErrorHandler != nil {
+		l.millErrs = append(l.millErrs, err)
+	}
+	if l.millErr == nil && !errors.Is(err, ErrOverTotalSize) {
+		l.millErr = err
+	}
+}
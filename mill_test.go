@@ -0,0 +1,256 @@
+package logrotate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestMillKeepsQueuedBackups rotates faster than the mill compresses, so
+// the retention sees backups still waiting to be compressed.
+func TestMillKeepsQueuedBackups(t *testing.T) {
+	dir := t.TempDir()
+	l := &Logrotate{
+		Filename:     filepath.Join(dir, "app.log"),
+		MaxSize:      100,
+		MaxBackups:   3,
+		AsyncCleanup: true,
+		Compress:     true,
+	}
+
+	line := append(bytes.Repeat([]byte("x"), 59), '\n')
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := l.Write(line); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files := backupFiles(t, dir)
+	if len(files) != 3 {
+		t.Fatalf("backups = %q, want 3", files)
+	}
+	for _, name := range files {
+		if !strings.HasSuffix(name, ".gz") {
+			t.Errorf("backup %s is not compressed", name)
+		}
+	}
+}
+
+func TestMillExitsOnClose(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithMaxBackups(1), WithClock(newTestClock()))
+	l.AsyncCleanup = true
+
+	write(t, l, "123456789\n")
+	write(t, l, "123456789\n")
+	l.mu.Lock()
+	done := l.millDone
+	l.mu.Unlock()
+	if done == nil {
+		t.Fatal("no mill started by the rotation")
+	}
+
+	closeLog(t, l)
+	select {
+	case <-done:
+	default:
+		t.Fatal("mill still running after Close")
+	}
+
+	// a later rotation starts a new mill, stopped by the next Close.
+	write(t, l, "123456789\n")
+	write(t, l, "123456789\n")
+	closeLog(t, l)
+	if l.millCh != nil || l.millDone != nil {
+		t.Fatal("mill still set after Close")
+	}
+	if n := len(backupFiles(t, filepath.Dir(filename))); n != 1 {
+		t.Fatalf("got %d backups, want 1 kept by the mill", n)
+	}
+}
+
+// slowCompressor blocks each compression until release is closed.
+type slowCompressor struct {
+	release chan struct{}
+}
+
+func (slowCompressor) Extension() string { return ".slow" }
+
+func (c slowCompressor) NewWriter(dst io.Writer) (io.WriteCloser, error) {
+	<-c.release
+	return upperWriter{dst}, nil
+}
+
+func TestCloseContextDeadline(t *testing.T) {
+	filename := testFilename(t)
+	c := slowCompressor{release: make(chan struct{})}
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithCompress(true))
+	l.AsyncCleanup = true
+	l.Compressor = c
+	write(t, l, "123456789\n")
+	write(t, l, "abc\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.CloseContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("CloseContext = %v, want %v", err, context.DeadlineExceeded)
+	}
+	l.mu.Lock()
+	open := l.file != nil
+	l.mu.Unlock()
+	if open {
+		t.Fatal("file still open after CloseContext")
+	}
+
+	close(c.release)
+	closeLog(t, l)
+	files := backupFiles(t, filepath.Dir(filename))
+	if len(files) != 1 || filepath.Ext(files[0]) != ".slow" {
+		t.Fatalf("backups = %q, want one .slow", files)
+	}
+}
+
+func TestCloseConcurrently(t *testing.T) {
+	l := newLogrotate(testFilename(t), WithMaxSizeBytes(10), WithCompress(true))
+	l.AsyncCleanup = true
+	write(t, l, "123456789\n")
+	write(t, l, "abc\n")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- l.Close()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("second Close = %v", err)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	filename := testFilename(t)
+	dir := filepath.Dir(filename)
+	clock := newTestClock()
+	l := newLogrotate(filename, WithCompress(true), WithClock(clock))
+	l.AsyncCleanup = true
+	defer closeLog(t, l)
+
+	write(t, l, "first\n")
+	if err := l.Drain(); err != nil {
+		t.Fatal(err)
+	}
+	files := backupFiles(t, dir)
+	if len(files) != 1 || !strings.HasSuffix(files[0], ".gz") {
+		t.Fatalf("backups = %q, want one .gz", files)
+	}
+	if got := gunzip(t, filepath.Join(dir, files[0])); got != "first\n" {
+		t.Fatalf("backup = %q", got)
+	}
+
+	// an empty file is not rotated again.
+	if err := l.Drain(); err != nil {
+		t.Fatal(err)
+	}
+	if got := backupFiles(t, dir); len(got) != 1 {
+		t.Fatalf("backups = %q, want 1 after a second Drain", got)
+	}
+
+	write(t, l, "second\n")
+	if got := readFile(t, filename); got != "second\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
+
+// TestDrainErrorHandler has the mill report ErrOverTotalSize to an
+// ErrorHandler using the Logrotate while Drain waits for the mill.
+func TestDrainErrorHandler(t *testing.T) {
+	l := newLogrotate(testFilename(t), WithCompress(true))
+	l.MaxTotalSize = 10
+	l.AsyncCleanup = true
+	var handled int
+	l.ErrorHandler = func(err error) {
+		if errors.Is(err, ErrOverTotalSize) {
+			handled++
+		}
+		l.Size()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			write(t, l, "more than ten bytes\n")
+			if err := l.Drain(); err != nil && !errors.Is(err, ErrOverTotalSize) {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Drain deadlocked")
+	}
+	closeLog(t, l)
+	if handled == 0 {
+		t.Fatal("ErrOverTotalSize not reported")
+	}
+}
+
+// failCompressor fails every compression with errCompress.
+type failCompressor struct{}
+
+var errCompress = errors.New("compress failed")
+
+func (failCompressor) Extension() string { return ".fail" }
+
+func (failCompressor) NewWriter(io.Writer) (io.WriteCloser, error) {
+	return nil, errCompress
+}
+
+func TestMillErrorHandler(t *testing.T) {
+	l := newLogrotate(testFilename(t), WithMaxSizeBytes(10), WithCompress(true), WithClock(newTestClock()))
+	l.AsyncCleanup = true
+	l.Compressor = failCompressor{}
+	var handled []error
+	l.ErrorHandler = func(err error) {
+		handled = append(handled, err)
+	}
+
+	write(t, l, "123456789\n")
+	write(t, l, "abc\n")
+	if err := l.Close(); !errors.Is(err, errCompress) {
+		t.Fatalf("Close = %v, want %v", err, errCompress)
+	}
+	if len(handled) != 1 || !errors.Is(handled[0], errCompress) {
+		t.Fatalf("ErrorHandler got %v, want the compression error", handled)
+	}
+}
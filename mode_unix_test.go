@@ -0,0 +1,50 @@
+//go:build !windows && !plan9
+
+package logrotate
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestStrictModeIgnoresUmask(t *testing.T) {
+	old := syscall.Umask(0077)
+	defer syscall.Umask(old)
+
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(newTestClock()))
+	l.FileMode = 0644
+	l.StrictMode = true
+	defer closeLog(t, l)
+
+	for _, s := range []string{"123456789\n", "rotated\n"} {
+		write(t, l, s)
+		info, err := os.Stat(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != 0644 {
+			t.Fatalf("mode after %q = %v, want 0644", s, got)
+		}
+	}
+}
+
+func TestDirModeSetgid(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs", "app")
+	l := newLogrotate(filepath.Join(dir, "app.log"))
+	l.DirMode = 0775 | os.ModeSetgid
+	write(t, l, "abc\n")
+	closeLog(t, l)
+
+	for _, d := range []string{dir, filepath.Dir(dir)} {
+		info, err := os.Stat(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode()&os.ModeSetgid == 0 || info.Mode().Perm() != 0775 {
+			t.Errorf("%s: mode = %v, want setgid and 0775", d, info.Mode())
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package logrotate
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Option configures a Logrotate created by New.
+type Option func(*Logrotate)
+
+// New return logrotate struct with name of file configured by options.
+// Without options the file is rotated at the default size.
+func New(filename string, opts ...Option) io.WriteCloser {
+	return newLogrotate(filename, opts...)
+}
+
+// newLogrotate returns the Logrotate of New.
+func newLogrotate(filename string, opts ...Option) *Logrotate {
+	l := &Logrotate{
+		Filename: filename,
+		MaxSize:  defaultSize * Megabyte,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// WithMaxSize sets the maximum size (Mbyte) of file, a size below 1 uses the
+// default of 10 Mbyte.
+func WithMaxSize(size int64) Option {
+	return func(l *Logrotate) {
+		if size < 1 {
+			size = defaultSize
+		}
+		l.MaxSize = Megabytes(size)
+	}
+}
+
+// WithMaxSizeBytes sets the maximum size of file in bytes, for example
+// 10*DecimalMegabyte.
+func WithMaxSizeBytes(size int64) Option {
+	return func(l *Logrotate) {
+		if size > 0 {
+			l.MaxSize = size
+		}
+	}
+}
+
+// WithSizeString sets the maximum size of file from a string accepted by
+// ParseSize, like "250MB". An invalid or zero size leaves the size unchanged
+// and is returned by Validate.
+func WithSizeString(size string) Option {
+	return func(l *Logrotate) {
+		n, err := ParseSize(size)
+		if err == nil && n == 0 {
+			err = fmt.Errorf("logrotate: invalid size %q", size)
+		}
+		if err != nil {
+			l.optErrs = append(l.optErrs, err)
+			return
+		}
+		l.MaxSize = n
+	}
+}
+
+// WithMaxBackups sets the maximum number of retained backups.
+func WithMaxBackups(n int) Option {
+	return func(l *Logrotate) {
+		l.MaxBackups = n
+	}
+}
+
+// WithMaxAge sets the maximum age of retained backups.
+func WithMaxAge(d time.Duration) Option {
+	return func(l *Logrotate) {
+		l.MaxAge = d
+	}
+}
+
+// WithKeepMinimum keeps the n newest backups regardless of the other
+// retention limits.
+func WithKeepMinimum(n int) Option {
+	return func(l *Logrotate) {
+		l.KeepMinimum = n
+	}
+}
+
+// WithMaxTotalSize sets the maximum size (Mbyte) of all backups together.
+func WithMaxTotalSize(size int64) Option {
+	return func(l *Logrotate) {
+		l.MaxTotalSize = Megabytes(size)
+	}
+}
+
+// WithUTC uses UTC instead of local time in backup names.
+func WithUTC(utc bool) Option {
+	return func(l *Logrotate) {
+		l.UTC = utc
+	}
+}
+
+// WithBufferSize buffers up to n bytes in memory before writing the file.
+func WithBufferSize(n int) Option {
+	return func(l *Logrotate) {
+		l.BufferSize = n
+	}
+}
+
+// WithClock sets the time source, the system clock by default.
+func WithClock(c Clock) Option {
+	return func(l *Logrotate) {
+		l.clock = c
+	}
+}
+
+// WithEventChan sends an event for each rotation to ch. Events are dropped
+// when ch is full, so writes never wait for the receiver. The channel is not
+// closed.
+func WithEventChan(ch chan<- RotationEvent) Option {
+	return func(l *Logrotate) {
+		l.events = ch
+	}
+}
+
+// WithCompress enables gzip compression of backups.
+func WithCompress(compress bool) Option {
+	return func(l *Logrotate) {
+		l.Compress = compress
+	}
+}
@@ -0,0 +1,71 @@
+package logrotate
+
+import (
+	"testing"
+	"time"
+)
+
+// settings are the fields of a Logrotate set by the options, comparable
+// with ==.
+type settings struct {
+	MaxSize      int64
+	MaxBackups   int
+	MaxAge       time.Duration
+	KeepMinimum  int
+	MaxTotalSize int64
+	Compress     bool
+	BufferSize   int
+	UTC          bool
+}
+
+func settingsOf(l *Logrotate) settings {
+	return settings{
+		MaxSize:      l.MaxSize,
+		MaxBackups:   l.MaxBackups,
+		MaxAge:       l.MaxAge,
+		KeepMinimum:  l.KeepMinimum,
+		MaxTotalSize: l.MaxTotalSize,
+		Compress:     l.Compress,
+		BufferSize:   l.BufferSize,
+		UTC:          l.UTC,
+	}
+}
+
+func TestNewOptions(t *testing.T) {
+	const def = 10 * Megabyte
+	tests := []struct {
+		name string
+		opts []Option
+		want settings
+	}{
+		{"defaults", nil, settings{MaxSize: def}},
+		{"size", []Option{WithMaxSize(5)}, settings{MaxSize: 5 * Megabyte}},
+		{"zero size", []Option{WithMaxSize(0)}, settings{MaxSize: def}},
+		{"bytes", []Option{WithMaxSizeBytes(1000)}, settings{MaxSize: 1000}},
+		{
+			"retention",
+			[]Option{WithMaxBackups(3), WithMaxAge(24 * time.Hour), WithKeepMinimum(1), WithMaxTotalSize(100)},
+			settings{MaxSize: def, MaxBackups: 3, MaxAge: 24 * time.Hour, KeepMinimum: 1, MaxTotalSize: 100 * Megabyte},
+		},
+		{
+			"compress and buffer",
+			[]Option{WithCompress(true), WithBufferSize(4096), WithUTC(true)},
+			settings{MaxSize: def, Compress: true, BufferSize: 4096, UTC: true},
+		},
+		{"last wins", []Option{WithMaxBackups(3), WithMaxBackups(7)}, settings{MaxSize: def, MaxBackups: 7}},
+	}
+
+	for _, tt := range tests {
+		w := New("app.log", tt.opts...)
+		l, ok := w.(*Logrotate)
+		if !ok {
+			t.Fatalf("%s: New returned %T", tt.name, w)
+		}
+		if l.Filename != "app.log" {
+			t.Errorf("%s: Filename = %q", tt.name, l.Filename)
+		}
+		if got := settingsOf(l); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+//go:build linux
+
+package logrotate
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// fallocKeepSize is FALLOC_FL_KEEP_SIZE, it allocates blocks without
+// changing the size of the file.
+const fallocKeepSize = 0x1
+
+// preallocate reserves MaxSize bytes on disk for f with Preallocate. A file
+// system without support is ignored, other failures only reported.
+func (l *Logrotate) preallocate(f file) {
+	if !l.Preallocate {
+		return
+	}
+
+	fd, ok := f.(interface{ Fd() uintptr })
+	if !ok {
+		return
+	}
+
+	err := syscall.Fallocate(int(fd.Fd()), fallocKeepSize, 0, l.maxSize())
+	if err != nil && !errors.Is(err, syscall.EOPNOTSUPP) && !errors.Is(err, syscall.ENOSYS) {
+		l.reportError(fmt.Errorf("logrotate: preallocate %q: %w", l.Filename, err))
+	}
+}
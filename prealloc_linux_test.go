@@ -0,0 +1,40 @@
+//go:build linux
+
+package logrotate
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestPreallocate(t *testing.T) {
+	const size = 1 << 20
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithMaxSizeBytes(size), WithClock(newTestClock()))
+	l.Preallocate = true
+	defer closeLog(t, l)
+
+	write(t, l, "abc\n")
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 4 {
+		t.Fatalf("size = %d, want the 4 bytes written", info.Size())
+	}
+	if blocks := info.Sys().(*syscall.Stat_t).Blocks; blocks*512 < size {
+		t.Skipf("%d bytes allocated, the file system ignores fallocate", blocks*512)
+	}
+
+	// rotation goes by the bytes written, not the allocated ones.
+	write(t, l, string(make([]byte, size-4)))
+	if files := backupFiles(t, filepath.Dir(filename)); len(files) != 0 {
+		t.Fatalf("backups = %q, want none at MaxSize", files)
+	}
+	write(t, l, "x")
+	if files := backupFiles(t, filepath.Dir(filename)); len(files) != 1 {
+		t.Fatalf("backups = %q, want 1 past MaxSize", files)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !linux
+
+package logrotate
+
+// preallocate does nothing, only Linux supports Preallocate.
+func (l *Logrotate) preallocate(f file) {}
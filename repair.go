@@ -0,0 +1,77 @@
+package logrotate
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+)
+
+// repairBackups cleans up after compressions of earlier runs which did not
+// finish, with RepairBackups.
+func (l *Logrotate) repairBackups() error {
+	if !l.RepairBackups {
+		return nil
+	}
+
+	list, err := l.backups()
+	if err != nil {
+		return err
+	}
+
+	ext := l.compressExt()
+	for _, b := range list {
+		if !b.plain {
+			if l.Compressor == nil && len(l.CompressCommand) == 0 {
+				if err := checkGzip(b.path + ext); err != nil {
+					l.reportError(fmt.Errorf("logrotate: check %q: %w", b.path+ext, err))
+				}
+			}
+			continue
+		}
+
+		if err := l.removeFile(b.path + ext + tempSuffix); err != nil {
+			return err
+		}
+		if !b.compressed {
+			continue
+		}
+
+		// the uncompressed file is only removed once compressed, so the
+		// compressed one is partial.
+		if err := l.removeFile(b.path + ext); err != nil {
+			return err
+		}
+		if !l.Compress || l.CompressExisting {
+			// compressExisting compresses it next.
+			continue
+		}
+
+		if l.AsyncCleanup {
+			l.queueMill(b.path)
+			continue
+		}
+		if err := l.finishBackup(b.path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkGzip returns an error unless the file name is a complete gzip stream.
+func checkGzip(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(io.Discard, r)
+	return err
+}
@@ -0,0 +1,75 @@
+package logrotate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRepairBackups(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		filename := testFilename(t)
+		dir := filepath.Dir(filename)
+		clock := newTestClock()
+		name := writeBackup(t, filename, clock.Now().Add(-time.Hour), "original\n")
+		path := filepath.Join(dir, name)
+
+		// a truncated compression and a left temporary file.
+		if err := os.WriteFile(path+".gz", []byte("\x1f\x8b"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path+".gz"+tempSuffix, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		l := newLogrotate(filename, WithCompress(compress), WithClock(clock))
+		l.RepairBackups = true
+		write(t, l, "new\n")
+		closeLog(t, l)
+
+		if compress {
+			if got := backupFiles(t, dir); !reflect.DeepEqual(got, []string{name + ".gz"}) {
+				t.Fatalf("compress: backups = %q, want %s.gz", got, name)
+			}
+			if got := gunzip(t, path+".gz"); got != "original\n" {
+				t.Fatalf("compress: backup = %q", got)
+			}
+			continue
+		}
+		if got := backupFiles(t, dir); !reflect.DeepEqual(got, []string{name}) {
+			t.Fatalf("backups = %q, want %s", got, name)
+		}
+		if got := readFile(t, path); got != "original\n" {
+			t.Fatalf("backup = %q", got)
+		}
+	}
+}
+
+func TestRepairBackupsReportsCorrupt(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	name := writeBackup(t, filename, clock.Now().Add(-time.Hour), "not gzip")
+	path := filepath.Join(filepath.Dir(filename), name+".gz")
+	if err := os.Rename(filepath.Join(filepath.Dir(filename), name), path); err != nil {
+		t.Fatal(err)
+	}
+
+	var errs []error
+	l := newLogrotate(filename, WithCompress(true), WithClock(clock))
+	l.RepairBackups = true
+	l.ErrorHandler = func(err error) { errs = append(errs, err) }
+	write(t, l, "new\n")
+	closeLog(t, l)
+
+	// a compressed backup alone is the only copy, it is kept.
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), path) || errors.Unwrap(errs[0]) == nil {
+		t.Fatalf("reported %v, want the check error of %s", errs, path)
+	}
+	if !exists(path) {
+		t.Fatalf("%s removed", path)
+	}
+}
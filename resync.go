@@ -0,0 +1,30 @@
+package logrotate
+
+import "fmt"
+
+// resyncSize corrects the counted size of the open file from its size on
+// disk, at most once per SizeResyncInterval. Data waiting in the buffer of
+// BufferSize is counted on top.
+func (l *Logrotate) resyncSize() {
+	if l.SizeResyncInterval <= 0 || l.file == nil {
+		return
+	}
+
+	now := l.timeNow()
+	if !l.sizeChecked.IsZero() && now.Sub(l.sizeChecked) < l.SizeResyncInterval {
+		return
+	}
+	l.sizeChecked = now
+
+	info, err := l.file.Stat()
+	if err != nil {
+		l.reportError(fmt.Errorf("logrotate: stat %q: %w", l.Filename, err))
+		return
+	}
+
+	size := info.Size()
+	if l.buf != nil {
+		size += int64(l.buf.Buffered())
+	}
+	l.size = size
+}
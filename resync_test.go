@@ -0,0 +1,30 @@
+package logrotate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSizeResync(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	l := newLogrotate(filename, WithMaxSizeBytes(20), WithClock(clock))
+	l.SizeResyncInterval = time.Minute
+	defer closeLog(t, l)
+
+	write(t, l, "123456789012345\n")
+	if err := os.Truncate(filename, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(2 * time.Minute)
+	write(t, l, "abcdefgh\n")
+	if files := backupFiles(t, filepath.Dir(filename)); len(files) != 0 {
+		t.Fatalf("backups = %q, want no rotation of the truncated file", files)
+	}
+	if got := l.Size(); got != 9 {
+		t.Fatalf("Size() = %d, want 9 after the resync", got)
+	}
+}
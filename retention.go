@@ -0,0 +1,38 @@
+package logrotate
+
+import "fmt"
+
+// RetentionPolicy selects what happens when a rotation would exceed
+// MaxBackups.
+type RetentionPolicy int
+
+const (
+	// DropOldest removes the oldest backups beyond MaxBackups after each
+	// rotation.
+	DropOldest RetentionPolicy = iota
+
+	// FailOnExceed never removes a backup for MaxBackups. A rotation which
+	// would create one backup too many fails with ErrRetentionExceeded, and
+	// Write keeps appending to the current file past MaxSize.
+	FailOnExceed
+
+	// BlockUntilFreed is reserved and behaves like DropOldest for now.
+	BlockUntilFreed
+)
+
+// checkRetention fails a rotation with FailOnExceed if MaxBackups backups
+// exist already.
+func (l *Logrotate) checkRetention() error {
+	if l.RetentionPolicy != FailOnExceed || l.MaxBackups <= 0 {
+		return nil
+	}
+
+	list, err := l.backups()
+	if err != nil {
+		return err
+	}
+	if len(list) >= l.MaxBackups {
+		return fmt.Errorf("%w: %q", ErrRetentionExceeded, l.Filename)
+	}
+	return nil
+}
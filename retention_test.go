@@ -0,0 +1,46 @@
+package logrotate
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRetentionPolicy(t *testing.T) {
+	for _, policy := range []RetentionPolicy{DropOldest, FailOnExceed} {
+		filename := testFilename(t)
+		var errs []error
+		l := newLogrotate(filename, WithMaxSizeBytes(10), WithMaxBackups(2), WithClock(newTestClock()))
+		l.RetentionPolicy = policy
+		l.ErrorHandler = func(err error) { errs = append(errs, err) }
+		for i := 0; i < 5; i++ {
+			write(t, l, "123456789\n")
+		}
+		rerr := l.Rotate()
+		closeLog(t, l)
+
+		files := backupFiles(t, filepath.Dir(filename))
+		if len(files) != 2 {
+			t.Fatalf("policy %d: backups = %q, want 2", policy, files)
+		}
+		if policy == DropOldest {
+			if rerr != nil || len(errs) != 0 {
+				t.Fatalf("DropOldest: Rotate = %v, reported %v", rerr, errs)
+			}
+			continue
+		}
+
+		// the writes after the second backup stay in the file, and each
+		// failed rotation of Write is reported.
+		if !errors.Is(rerr, ErrRetentionExceeded) {
+			t.Fatalf("FailOnExceed: Rotate = %v, want ErrRetentionExceeded", rerr)
+		}
+		if len(errs) != 2 || !errors.Is(errs[0], ErrRetentionExceeded) {
+			t.Fatalf("FailOnExceed: reported %v", errs)
+		}
+		if got := readFile(t, filename); got != strings.Repeat("123456789\n", 3) {
+			t.Fatalf("FailOnExceed: file = %q", got)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package logrotate
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// RotateMode selects how the current file becomes a backup.
+type RotateMode int
+
+const (
+	// MoveCreateMode renames the current file to the backup and creates a
+	// new file.
+	MoveCreateMode RotateMode = iota
+
+	// CopyTruncateMode copies the current file to the backup and truncates
+	// it in place, so the file keeps its inode. Writes by other processes
+	// between the copy and the truncate are lost.
+	CopyTruncateMode
+)
+
+// copyTruncate rotates the open file in CopyTruncateMode.
+func (l *Logrotate) copyTruncate() error {
+	err := l.flush()
+	if err != nil {
+		return err
+	}
+
+	name, err := l.nextBackup()
+	if err != nil {
+		return err
+	}
+
+	err = l.copyFile(l.Filename, name)
+	if err != nil {
+		return err
+	}
+
+	err = l.file.Truncate(0)
+	if err != nil {
+		return fmt.Errorf("logrotate: truncate %q: %w", l.Filename, err)
+	}
+
+	_, err = l.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("logrotate: seek %q: %w", l.Filename, err)
+	}
+
+	l.size = 0
+	l.lines = 0
+	l.openTime = l.timeNow()
+	name = l.postRotate(name)
+	l.queueBackup(name)
+
+	err = l.writeHeader()
+	if err != nil {
+		return err
+	}
+
+	l.rotatedTo(name)
+	return l.cleanup(name)
+}
+
+// copyFile copies src to the new file dst. A partial dst is removed on
+// failure.
+func (l *Logrotate) copyFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("logrotate: open %q: %w", src, err)
+	}
+	defer in.Close()
+
+	out, err := l.filesystem().OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, l.fileMode())
+	if err != nil {
+		return fmt.Errorf("logrotate: open %q: %w", dst, err)
+	}
+
+	defer func() {
+		if err != nil {
+			l.filesystem().Remove(dst)
+		}
+	}()
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("logrotate: copy %q: %w", src, err)
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("logrotate: close %q: %w", dst, err)
+	}
+
+	return l.syncDir(dst)
+}
@@ -0,0 +1,35 @@
+package logrotate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyTruncateKeepsFile(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(newTestClock()))
+	l.RotateMode = CopyTruncateMode
+	defer closeLog(t, l)
+
+	write(t, l, "123456789\n")
+	before, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, l, "abc\n")
+	after, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !os.SameFile(before, after) {
+		t.Fatal("CopyTruncateMode replaced the file")
+	}
+	if got := readFile(t, filename); got != "abc\n" {
+		t.Fatalf("file = %q", got)
+	}
+	if got := backupContents(t, filepath.Dir(filename)); len(got) != 1 || got[0] != "123456789\n" {
+		t.Fatalf("backups = %q, want the old content", got)
+	}
+}
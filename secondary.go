@@ -0,0 +1,49 @@
+package logrotate
+
+import (
+	"fmt"
+	"io"
+)
+
+// mirror writes p, already written to the file, to Secondary.
+func (l *Logrotate) mirror(p []byte) {
+	if l.Secondary == nil || l.secondaryClosed {
+		return
+	}
+
+	n, err := l.Secondary.Write(p)
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		l.reportError(fmt.Errorf("logrotate: write secondary: %w", err))
+	}
+}
+
+// mirrorString is mirror for a string.
+func (l *Logrotate) mirrorString(s string) {
+	if l.Secondary == nil || l.secondaryClosed {
+		return
+	}
+
+	n, err := io.WriteString(l.Secondary, s)
+	if err == nil && n < len(s) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		l.reportError(fmt.Errorf("logrotate: write secondary: %w", err))
+	}
+}
+
+// closeSecondary closes Secondary with CloseSecondary, once.
+func (l *Logrotate) closeSecondary() {
+	c, ok := l.Secondary.(io.Closer)
+	if !l.CloseSecondary || !ok || l.secondaryClosed {
+		return
+	}
+	l.secondaryClosed = true
+
+	if err := c.Close(); err != nil {
+		l.reportError(fmt.Errorf("logrotate: close secondary: %w", err))
+	}
+}
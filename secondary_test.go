@@ -0,0 +1,106 @@
+package logrotate
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+var errSinkDown = errors.New("sink down")
+
+// failingSink fails every write and records whether it was closed.
+type failingSink struct {
+	closed bool
+}
+
+// closeSink records the data written and the calls of Close.
+type closeSink struct {
+	bytes.Buffer
+	closes int
+}
+
+func (s *closeSink) Close() error {
+	s.closes++
+	return nil
+}
+
+func (*failingSink) Write(p []byte) (int, error) { return 0, errSinkDown }
+
+func (s *failingSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestSecondary(t *testing.T) {
+	filename := testFilename(t)
+	var copied bytes.Buffer
+	l := newLogrotate(filename)
+	l.Secondary = &copied
+	write(t, l, "one\n")
+	if _, err := l.WriteString("two\n"); err != nil {
+		t.Fatal(err)
+	}
+	closeLog(t, l)
+
+	if got := copied.String(); got != "one\ntwo\n" {
+		t.Fatalf("secondary = %q", got)
+	}
+	if got := readFile(t, filename); got != "one\ntwo\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
+
+func TestSecondaryFails(t *testing.T) {
+	for _, closeSecondary := range []bool{false, true} {
+		filename := testFilename(t)
+		sink := &failingSink{}
+		var errs []error
+		l := newLogrotate(filename)
+		l.Secondary = sink
+		l.CloseSecondary = closeSecondary
+		l.ErrorHandler = func(err error) { errs = append(errs, err) }
+
+		if n, err := l.Write([]byte("abc\n")); n != 4 || err != nil {
+			t.Fatalf("Write = %d, %v, want the file write to succeed", n, err)
+		}
+		if n, err := l.WriteString("def\n"); n != 4 || err != nil {
+			t.Fatalf("WriteString = %d, %v, want the file write to succeed", n, err)
+		}
+		closeLog(t, l)
+
+		if len(errs) != 2 || !errors.Is(errs[0], errSinkDown) || !errors.Is(errs[1], errSinkDown) {
+			t.Fatalf("reported %v, want two secondary errors", errs)
+		}
+		if sink.closed != closeSecondary {
+			t.Fatalf("CloseSecondary %t: secondary closed %t", closeSecondary, sink.closed)
+		}
+		if got := readFile(t, filename); got != "abc\ndef\n" {
+			t.Fatalf("file = %q", got)
+		}
+	}
+}
+
+func TestSecondaryClosedOnce(t *testing.T) {
+	filename := testFilename(t)
+	sink := &closeSink{}
+	l := newLogrotate(filename)
+	l.Secondary = sink
+	l.CloseSecondary = true
+	write(t, l, "one\n")
+	closeLog(t, l)
+	closeLog(t, l)
+
+	// the file is opened again, the closed secondary is left alone.
+	write(t, l, "two\n")
+	closeLog(t, l)
+
+	if sink.closes != 1 {
+		t.Fatalf("secondary closed %d times, want once", sink.closes)
+	}
+	if got := sink.String(); got != "one\n" {
+		t.Fatalf("secondary = %q, want no data after Close", got)
+	}
+	if got := readFile(t, filename); got != "one\ntwo\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
@@ -0,0 +1,85 @@
+package logrotate
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// ReopenOnSignal calls Reopen whenever one of sigs is received, usually
+// syscall.SIGHUP sent by logrotate(8) after moving the file. Errors go to
+// ErrorHandler. The returned function stops the handling and waits until it
+// has finished.
+func (l *Logrotate) ReopenOnSignal(sigs ...os.Signal) (stop func()) {
+	return onSignal(l.reopenReported, sigs...)
+}
+
+// reopenReported reopens the file and reports an error to ErrorHandler.
+func (l *Logrotate) reopenReported() {
+	l.mu.Lock()
+	defer l.unlock()
+
+	l.reportError(l.reopen())
+}
+
+// InstallSignalHandler flushes and syncs the current file whenever one of
+// sigs is received, usually syscall.SIGTERM before a container stops, and
+// with rotate also rotates it so the last file is sealed. Nothing is done
+// once the file was closed. Errors go to ErrorHandler. The returned function
+// uninstalls the handler and waits until it has finished.
+func (l *Logrotate) InstallSignalHandler(rotate bool, sigs ...os.Signal) (uninstall func()) {
+	return onSignal(func() { l.seal(rotate) }, sigs...)
+}
+
+// seal flushes and syncs the open file, and rotates it with rotate.
+func (l *Logrotate) seal(rotate bool) {
+	l.mu.Lock()
+	defer l.unlock()
+
+	if l.file == nil {
+		return
+	}
+
+	err := l.flush()
+	if err == nil {
+		err = l.file.Sync()
+		if err != nil {
+			err = fmt.Errorf("logrotate: sync %q: %w", l.Filename, err)
+		}
+	}
+	if err == nil && rotate && l.size > 0 {
+		err = l.rotateFile(RotateManual)
+	}
+	l.reportError(err)
+}
+
+// onSignal calls fn whenever one of sigs is received, until the returned
+// function is called.
+func onSignal(fn func(), sigs ...os.Signal) (stop func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		for {
+			select {
+			case <-ch:
+				fn()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+			<-exited
+		})
+	}
+}
@@ -0,0 +1,110 @@
+//go:build !windows && !plan9
+
+package logrotate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReopenOnSignal(t *testing.T) {
+	filename := testFilename(t)
+	l := NewLogrotateT(filename, 10)
+	defer closeLog(t, l)
+
+	stop := l.ReopenOnSignal(syscall.SIGHUP)
+	defer stop()
+
+	write(t, l, "before\n")
+	if err := os.Rename(filename, filename+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+
+	// the file is created again by Reopen.
+	deadline := time.Now().Add(5 * time.Second)
+	for !exists(filename) {
+		if time.Now().After(deadline) {
+			t.Fatal("file not reopened after SIGHUP")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	stop()
+	write(t, l, "after\n")
+	if got := readFile(t, filename); got != "after\n" {
+		t.Fatalf("file = %q", got)
+	}
+}
+
+func TestReopenOnSignalError(t *testing.T) {
+	filename := testFilename(t)
+	l := NewLogrotateT(filename, 10)
+	l.NoCreateDir = true
+	errs := make(chan error, 1)
+	l.ErrorHandler = func(err error) {
+		errs <- err
+	}
+	defer l.Close()
+
+	stop := l.ReopenOnSignal(syscall.SIGHUP)
+	defer stop()
+
+	// the directory is gone, so the file cannot be opened again.
+	write(t, l, "before\n")
+	if err := os.RemoveAll(filepath.Dir(filename)); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("ErrorHandler got %v, want a missing directory", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("failed reopen not reported")
+	}
+}
+
+func TestInstallSignalHandler(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithBufferSize(4096))
+	defer closeLog(t, l)
+
+	uninstall := l.InstallSignalHandler(true, syscall.SIGUSR1)
+	defer uninstall()
+
+	write(t, l, "last words\n")
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+
+	// the buffered data is flushed into the sealed backup.
+	deadline := time.Now().Add(5 * time.Second)
+	for l.Rotations() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("file not sealed after SIGUSR1")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	uninstall()
+	if got := backupContents(t, filepath.Dir(filename)); len(got) != 1 || got[0] != "last words\n" {
+		t.Fatalf("backups = %q", got)
+	}
+
+	// a closed file is left alone.
+	closeLog(t, l)
+	l.seal(true)
+	if l.file != nil || l.Rotations() != 1 {
+		t.Fatal("seal opened or rotated a closed file")
+	}
+}
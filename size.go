@@ -0,0 +1,87 @@
+package logrotate
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits maps the lower case suffixes accepted by ParseSize to bytes.
+// Like MaxSize, KB, MB and GB are binary units, the same as KiB, MiB and GiB.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"kib", 1 << 10},
+	{"mib", 1 << 20},
+	{"gib", 1 << 30},
+	{"kb", 1 << 10},
+	{"mb", 1 << 20},
+	{"gb", 1 << 30},
+	{"b", 1},
+}
+
+// ParseSize parses a size like "10MB" or "512 KiB" and returns it in bytes.
+// Suffixes are case insensitive and binary, so MB and MiB both mean 1024*1024
+// bytes. A number without suffix is in bytes.
+func ParseSize(s string) (int64, error) {
+	str := strings.ToLower(strings.TrimSpace(s))
+
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(str, u.suffix) {
+			str = strings.TrimSpace(strings.TrimSuffix(str, u.suffix))
+			unit = u.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("logrotate: invalid size %q", s)
+	}
+
+	if n > math.MaxInt64/unit {
+		return 0, fmt.Errorf("logrotate: size %q overflows int64", s)
+	}
+
+	return n * unit, nil
+}
+
+// Megabytes returns n megabytes in bytes, limited to math.MaxInt64 and
+// math.MinInt64 instead of overflowing.
+func Megabytes(n int64) int64 {
+	switch {
+	case n > math.MaxInt64/Megabyte:
+		return math.MaxInt64
+	case n < math.MinInt64/Megabyte:
+		return math.MinInt64
+	}
+	return n * Megabyte
+}
+
+// FormatSize formats a size in bytes with a binary unit, like "1.5 MB".
+func FormatSize(bytes int64) string {
+	const units = "KMGTPE"
+
+	if bytes < 1024 && bytes > -1024 {
+		return strconv.FormatInt(bytes, 10) + " B"
+	}
+
+	value := float64(bytes)
+	i := -1
+	for (value >= 1024 || value <= -1024) && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+
+	s := strconv.FormatFloat(value, 'f', 1, 64)
+	if r, _ := strconv.ParseFloat(s, 64); (r >= 1024 || r <= -1024) && i < len(units)-1 {
+		// rounded up to the next unit, like 1023.99 KB.
+		i++
+		s = strconv.FormatFloat(value/1024, 'f', 1, 64)
+	}
+	s = strings.TrimSuffix(s, ".0")
+	return s + " " + units[i:i+1] + "B"
+}
@@ -0,0 +1,116 @@
+package logrotate
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"512", 512},
+		{"512b", 512},
+		{"10KB", 10 << 10},
+		{"10kib", 10 << 10},
+		{"250MB", 250 << 20},
+		{"250mb", 250 << 20},
+		{"250 MiB", 250 << 20},
+		{"  2 Gb ", 2 << 30},
+		{"1GiB", 1 << 30},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "MB", "ten MB", "-1MB", "1.5MB", "10TB", "10 M B", "9223372036854775807GB"} {
+		if got, err := ParseSize(in); err == nil || !strings.Contains(err.Error(), "size") {
+			t.Errorf("ParseSize(%q) = %d, %v, want an error", in, got, err)
+		}
+	}
+}
+
+func TestWithSizeString(t *testing.T) {
+	l := newLogrotate(testFilename(t), WithSizeString("250MB"))
+	if l.MaxSize != 250<<20 {
+		t.Fatalf("MaxSize = %d, want %d", l.MaxSize, 250<<20)
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestWithSizeStringInvalid(t *testing.T) {
+	for _, in := range []string{"250MBB", "0"} {
+		l := newLogrotate(testFilename(t), WithSizeString(in))
+		if l.MaxSize != defaultSize*Megabyte {
+			t.Errorf("%q: MaxSize = %d, want the default", in, l.MaxSize)
+		}
+		err := l.Validate()
+		if err == nil || !strings.Contains(err.Error(), in) {
+			t.Errorf("%q: Validate = %v, want an error naming the size", in, err)
+		}
+	}
+}
+
+func TestMegabytes(t *testing.T) {
+	for _, n := range []int64{0, 1, 3} {
+		if got := Megabytes(n); got != n*1024*1024 {
+			t.Errorf("Megabytes(%d) = %d", n, got)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1 KB"},
+		{1536, "1.5 KB"},
+		{Megabyte, "1 MB"},
+		{3 * Megabyte / 2, "1.5 MB"},
+		{10 * Megabyte, "10 MB"},
+		{1 << 30, "1 GB"},
+		{-1536, "-1.5 KB"},
+		{1048575, "1 MB"},
+		{-1048575, "-1 MB"},
+		{1<<30 - 1, "1 GB"},
+		{1<<20 - 52, "1023.9 KB"},
+	}
+	for _, tt := range tests {
+		if got := FormatSize(tt.in); got != tt.want {
+			t.Errorf("FormatSize(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMegabytesClamps(t *testing.T) {
+	if got := Megabytes(math.MaxInt64 / 2); got != math.MaxInt64 {
+		t.Errorf("Megabytes overflows to %d", got)
+	}
+	if got := Megabytes(math.MinInt64 / 2); got != math.MinInt64 {
+		t.Errorf("Megabytes underflows to %d", got)
+	}
+	if got := Megabytes(math.MaxInt64 / Megabyte); got != math.MaxInt64/Megabyte*Megabyte {
+		t.Errorf("Megabytes at the boundary = %d", got)
+	}
+}
+
+func TestHugeMaxSize(t *testing.T) {
+	l := NewLogrotateT(testFilename(t), math.MaxInt64/1000)
+	defer closeLog(t, l)
+
+	if l.MaxSize != math.MaxInt64 {
+		t.Fatalf("MaxSize = %d, want math.MaxInt64", l.MaxSize)
+	}
+	write(t, l, "abc\n")
+}
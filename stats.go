@@ -0,0 +1,139 @@
+package logrotate
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Stats describes the state of a Logrotate.
+type Stats struct {
+	Size    int64  // of the current file in bytes.
+	MaxSize int64  // before the file is rotated, in bytes.
+	Backups int    // number of backup files on disk.
+	File    string // path of the current file.
+}
+
+// Size returns the size in bytes of the current file.
+func (l *Logrotate) Size() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.size
+}
+
+// CurrentFile returns the path of the current file.
+func (l *Logrotate) CurrentFile() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.Filename
+}
+
+// SizeLocked returns the size in bytes of the current file without locking,
+// for ShouldRotate which is called with the lock held.
+func (l *Logrotate) SizeLocked() int64 {
+	return l.size
+}
+
+// OpenTimeLocked returns when the data of the current file started without
+// locking, for ShouldRotate which is called with the lock held.
+func (l *Logrotate) OpenTimeLocked() time.Time {
+	return l.openTime
+}
+
+// Rotations returns the number of rotations done, including manual ones.
+// It does not wait for a running Write.
+func (l *Logrotate) Rotations() uint64 {
+	return l.rotations.Load()
+}
+
+// LastRotation returns the time of the last rotation by the Clock, or the
+// zero time if the file was not rotated yet.
+func (l *Logrotate) LastRotation() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastRotation
+}
+
+// TotalSize returns the bytes on disk of the current file and all backups.
+// Data still buffered with BufferSize is not counted.
+func (l *Logrotate) TotalSize() (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	list, err := l.backups()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, b := range list {
+		total += b.size
+	}
+
+	info, err := l.filesystem().Stat(l.Filename)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("logrotate: stat %q: %w", l.Filename, err)
+	}
+	if err == nil {
+		total += info.Size()
+	}
+
+	return total, nil
+}
+
+// Stats returns the size of the current file and the number of backups.
+func (l *Logrotate) Stats() (Stats, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	list, err := l.backups()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	return Stats{
+		Size:    l.size,
+		MaxSize: l.maxSize(),
+		Backups: len(list),
+		File:    l.Filename,
+	}, nil
+}
+
+// WillRotate reports whether a Write of n bytes would rotate the file first,
+// by size, MaxLines counting the write as one record, or time. It changes
+// nothing, and neither ShouldRotate nor EpochFunc is asked. WillRotateWrite
+// counts the records of the data like Write.
+func (l *Logrotate) WillRotate(n int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	records := 0
+	if n > 0 {
+		records = 1
+	}
+	return l.willRotate(int64(n), records)
+}
+
+// WillRotateWrite is WillRotate for a Write of p.
+func (l *Logrotate) WillRotateWrite(p []byte) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.willRotate(int64(len(p)), l.countRecords(p))
+}
+
+// willRotate is WillRotate for a write of writeLen bytes holding records
+// records.
+func (l *Logrotate) willRotate(writeLen int64, records int) bool {
+	size, lines, openTime := l.size, l.lines, l.openTime
+	if l.file == nil {
+		// Write opens the file first, with the size on disk.
+		size, lines, openTime = 0, 0, l.timeNow()
+		if info, err := l.filesystem().Stat(l.Filename); err == nil && info.Size() > 0 {
+			size, openTime = info.Size(), info.ModTime()
+		}
+	}
+
+	_, ok := l.rotateDueFor(size, lines, openTime, writeLen, records, true)
+	return ok
+}
@@ -0,0 +1,170 @@
+package logrotate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSizeAndCurrentFile(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(newTestClock()))
+	defer closeLog(t, l)
+
+	if got := l.CurrentFile(); got != filename {
+		t.Fatalf("CurrentFile() = %q, want %q", got, filename)
+	}
+	write(t, l, "1234")
+	write(t, l, "5678")
+	if got := l.Size(); got != 8 {
+		t.Fatalf("Size() = %d, want 8", got)
+	}
+
+	write(t, l, "abc")
+	if got := l.Size(); got != 3 {
+		t.Fatalf("Size() after rotation = %d, want 3", got)
+	}
+
+	stats, err := l.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Stats{Size: 3, MaxSize: 10, Backups: 1, File: filename}
+	if stats != want {
+		t.Fatalf("Stats() = %+v, want %+v", stats, want)
+	}
+}
+
+func TestRotations(t *testing.T) {
+	l := newLogrotate(testFilename(t), WithMaxSizeBytes(10), WithClock(newTestClock()))
+	defer closeLog(t, l)
+
+	for i := 0; i < 3; i++ {
+		write(t, l, "123456789\n")
+	}
+	for i := 0; i < 2; i++ {
+		if err := l.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := l.Rotations(); got != 4 {
+		t.Fatalf("Rotations() = %d, want 4", got)
+	}
+}
+
+func TestLastRotation(t *testing.T) {
+	clock := newTestClock()
+	l := newLogrotate(testFilename(t), WithClock(clock))
+	defer closeLog(t, l)
+
+	if got := l.LastRotation(); !got.IsZero() {
+		t.Fatalf("LastRotation() = %v, want zero before a rotation", got)
+	}
+	write(t, l, "abc\n")
+	clock.Advance(time.Hour)
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.LastRotation(); !got.Equal(clock.Now()) {
+		t.Fatalf("LastRotation() = %v, want %v", got, clock.Now())
+	}
+}
+
+func TestTotalSize(t *testing.T) {
+	filename := testFilename(t)
+	dir := filepath.Dir(filename)
+	clock := newTestClock()
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(clock))
+	defer closeLog(t, l)
+
+	for _, s := range []string{"123456789\n", "abcdefg\n", "xyz\n"} {
+		write(t, l, s)
+		clock.Advance(time.Minute)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "other.log"), []byte("not counted"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := l.TotalSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(10 + 8 + 4); got != want {
+		t.Fatalf("TotalSize() = %d, want %d", got, want)
+	}
+}
+
+func TestWillRotate(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(newTestClock()))
+	defer closeLog(t, l)
+
+	// an empty file takes any write.
+	if l.WillRotate(100) {
+		t.Fatal("WillRotate(100) = true for an empty file")
+	}
+	write(t, l, "12345")
+	if l.WillRotate(5) {
+		t.Fatal("WillRotate(5) = true at 5 of 10 bytes")
+	}
+	if !l.WillRotate(6) {
+		t.Fatal("WillRotate(6) = false at 5 of 10 bytes")
+	}
+	write(t, l, "67890\n")
+	if n := len(backupFiles(t, filepath.Dir(filename))); n != 1 {
+		t.Fatalf("%d backups, want the rotation WillRotate saw", n)
+	}
+}
+
+func TestWillRotateWrite(t *testing.T) {
+	l := newLogrotate(testFilename(t), WithClock(newTestClock()))
+	l.MaxLines = 2
+	defer closeLog(t, l)
+
+	write(t, l, "a\n")
+	if l.WillRotate(4) {
+		t.Fatal("WillRotate(4) = true for one more record")
+	}
+	if !l.WillRotateWrite([]byte("b\nc\n")) {
+		t.Fatal("WillRotateWrite of two records = false")
+	}
+	if l.WillRotateWrite([]byte("b")) {
+		t.Fatal("WillRotateWrite without a record = true")
+	}
+}
+
+func TestWillRotateChangesNothing(t *testing.T) {
+	filename := testFilename(t)
+	clock := newTestClock()
+	if err := os.WriteFile(filename, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filename, clock.Now(), clock.Now()); err != nil {
+		t.Fatal(err)
+	}
+	epochs := 0
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(clock))
+	l.CronSchedule = "0 * * * *"
+	l.EpochFunc = func() string { epochs++; return "e" }
+	defer closeLog(t, l)
+
+	if !l.WillRotate(10) {
+		t.Fatal("WillRotate(10) = false for a file of 4 bytes")
+	}
+	if l.size != 0 || !l.openTime.IsZero() || l.cron != nil || !l.cronNext.IsZero() || epochs != 0 {
+		t.Fatalf("WillRotate changed the state: size %d, open time %v, cron %v, epochs %d", l.size, l.openTime, l.cronNext, epochs)
+	}
+
+	// the state of an open file stays too.
+	write(t, l, "x\n")
+	size, lines, openTime, cronNext := l.size, l.lines, l.openTime, l.cronNext
+	epochs = 0
+	clock.Advance(2 * time.Hour)
+	if !l.WillRotate(1) {
+		t.Fatal("WillRotate(1) = false past the cron time")
+	}
+	if l.size != size || l.lines != lines || !l.openTime.Equal(openTime) || !l.cronNext.Equal(cronNext) || epochs != 0 {
+		t.Fatal("WillRotate changed the state of the open file")
+	}
+}
@@ -0,0 +1,23 @@
+package logrotate
+
+import (
+	"io"
+	"log"
+)
+
+// NewStdLogger returns a log.Logger with the standard flags writing to a
+// Logrotate made by New, and the Closer of the Logrotate. The file is opened
+// right away, so an unusable filename is returned as error.
+func NewStdLogger(filename string, opts ...Option) (*log.Logger, io.Closer, error) {
+	l := newLogrotate(filename, opts...)
+
+	l.mu.Lock()
+	err := l.createFile()
+	l.unlock()
+	if err != nil {
+		l.Close()
+		return nil, nil, err
+	}
+
+	return log.New(l, "", log.LstdFlags), l, nil
+}
@@ -0,0 +1,30 @@
+package logrotate
+
+import (
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestNewStdLogger(t *testing.T) {
+	filename := testFilename(t)
+	logger, closer, err := NewStdLogger(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Print("one")
+	logger.Printf("two %d", 2)
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	stamp := `\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2} `
+	want := regexp.MustCompile("^" + stamp + "one\n" + stamp + "two 2\n$")
+	if got := readFile(t, filename); !want.MatchString(got) {
+		t.Fatalf("file = %q", got)
+	}
+
+	if _, _, err := NewStdLogger(filepath.Join(filename, "sub.log")); err == nil {
+		t.Fatal("NewStdLogger below a file succeeded")
+	}
+}
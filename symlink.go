@@ -0,0 +1,35 @@
+package logrotate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// updateSymlink points Symlink at the current file. A temporary link is
+// renamed over the old one, so the link never disappears. Failures are
+// only reported to ErrorHandler since the log itself is still written, for
+// example on systems without symlink support.
+func (l *Logrotate) updateSymlink() {
+	if l.Symlink == "" {
+		return
+	}
+
+	target, err := filepath.Abs(l.Filename)
+	if err != nil {
+		l.reportError(fmt.Errorf("logrotate: symlink %q: %w", l.Symlink, err))
+		return
+	}
+
+	tmp := l.Symlink + ".tmp"
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		l.reportError(fmt.Errorf("logrotate: symlink %q: %w", l.Symlink, err))
+		return
+	}
+
+	if err := os.Rename(tmp, l.Symlink); err != nil {
+		os.Remove(tmp)
+		l.reportError(fmt.Errorf("logrotate: symlink %q: %w", l.Symlink, err))
+	}
+}
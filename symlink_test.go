@@ -0,0 +1,55 @@
+package logrotate
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+
+	filename := testFilename(t)
+	link := filepath.Join(t.TempDir(), "current")
+	l := newLogrotate(filename, WithMaxSizeBytes(10))
+	l.Symlink = link
+	defer closeLog(t, l)
+
+	write(t, l, "123456789\n")
+	if got := readFile(t, link); got != "123456789\n" {
+		t.Fatalf("link before rotation reads %q", got)
+	}
+
+	write(t, l, "rotated\n")
+	if got := readFile(t, link); got != "rotated\n" {
+		t.Fatalf("link after rotation reads %q", got)
+	}
+
+	target, err := os.Readlink(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filepath.IsAbs(target) || target != filename {
+		t.Fatalf("link target = %q, want %q", target, filename)
+	}
+	if _, err := os.Lstat(link + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary link left behind: %v", err)
+	}
+}
+
+func TestSymlinkFailureDoesNotFailWrite(t *testing.T) {
+	filename := testFilename(t)
+	var reported []error
+	l := NewLogrotateT(filename, 10)
+	l.Symlink = filepath.Join(t.TempDir(), "missing", "current")
+	l.ErrorHandler = func(err error) { reported = append(reported, err) }
+	defer closeLog(t, l)
+
+	write(t, l, "x\n")
+	if len(reported) != 1 {
+		t.Fatalf("reported %v, want one symlink error", reported)
+	}
+}
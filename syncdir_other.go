@@ -0,0 +1,8 @@
+//go:build windows || plan9
+
+package logrotate
+
+// syncDir does nothing, directories can not be synced.
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,23 @@
+//go:build !windows && !plan9
+
+package logrotate
+
+import (
+	"fmt"
+	"os"
+)
+
+// syncDir commits the entries of directory dir to stable storage.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("logrotate: open dir %q: %w", dir, err)
+	}
+	defer d.Close()
+
+	err = d.Sync()
+	if err != nil {
+		return fmt.Errorf("logrotate: sync dir %q: %w", dir, err)
+	}
+	return nil
+}
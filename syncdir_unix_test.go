@@ -0,0 +1,24 @@
+//go:build !windows && !plan9
+
+package logrotate
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSyncDirRotation(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithMaxSizeBytes(10), WithClock(newTestClock()))
+	l.SyncDir = true
+	write(t, l, "123456789\n")
+	write(t, l, "abc\n")
+	closeLog(t, l)
+
+	if got := backupContents(t, filepath.Dir(filename)); len(got) != 1 || got[0] != "123456789\n" {
+		t.Fatalf("backups = %q", got)
+	}
+	if err := syncDir(filepath.Join(filepath.Dir(filename), "missing")); err == nil {
+		t.Fatal("syncDir of a missing directory succeeded")
+	}
+}
@@ -0,0 +1,48 @@
+package logrotate
+
+import (
+	"bytes"
+	"time"
+)
+
+// timeoutFile is a file whose writes give up after timeout with
+// ErrWriteTimeout. An abandoned write keeps running in its goroutine.
+type timeoutFile struct {
+	file
+	timeout time.Duration
+}
+
+// writeResult is the outcome of a write in background.
+type writeResult struct {
+	n   int
+	err error
+}
+
+func (f timeoutFile) Write(p []byte) (int, error) {
+	// the caller may reuse p once Write returned, before the write ends.
+	p = bytes.Clone(p)
+	return f.wait(func() (int, error) { return f.file.Write(p) })
+}
+
+func (f timeoutFile) WriteString(s string) (int, error) {
+	return f.wait(func() (int, error) { return f.file.WriteString(s) })
+}
+
+// wait runs write in a goroutine and waits at most timeout for it.
+func (f timeoutFile) wait(write func() (int, error)) (int, error) {
+	done := make(chan writeResult, 1)
+	go func() {
+		n, err := write()
+		done <- writeResult{n, err}
+	}()
+
+	timer := time.NewTimer(f.timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		return r.n, r.err
+	case <-timer.C:
+		return 0, ErrWriteTimeout
+	}
+}
@@ -0,0 +1,64 @@
+package logrotate
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowFS is the os fileSystem whose file writes wait for release.
+type slowFS struct {
+	osFS
+	release chan struct{}
+}
+
+func (fs slowFS) OpenFile(name string, flag int, perm os.FileMode) (file, error) {
+	f, err := fs.osFS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return slowFile{f, fs.release}, nil
+}
+
+type slowFile struct {
+	file
+	release chan struct{}
+}
+
+func (f slowFile) Write(p []byte) (int, error) {
+	<-f.release
+	return f.file.Write(p)
+}
+
+func (f slowFile) WriteString(s string) (int, error) {
+	<-f.release
+	return f.file.WriteString(s)
+}
+
+func TestWriteTimeout(t *testing.T) {
+	filename := testFilename(t)
+	release := make(chan struct{})
+	l := newLogrotate(filename)
+	l.WriteTimeout = 10 * time.Millisecond
+	l.fs = slowFS{release: release}
+
+	start := time.Now()
+	_, err := l.Write([]byte("stuck\n"))
+	if !errors.Is(err, ErrWriteTimeout) {
+		t.Fatalf("Write = %v, want ErrWriteTimeout", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("Write took %v", d)
+	}
+
+	// once the file system is back, writing goes on. The abandoned write
+	// may or may not reach the file.
+	close(release)
+	write(t, l, "back\n")
+	closeLog(t, l)
+	if got := readFile(t, filename); !strings.Contains(got, "back\n") {
+		t.Fatalf("file = %q", got)
+	}
+}
@@ -0,0 +1,93 @@
+package logrotate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Validate checks the settings before the first Write, so a service can
+// fail at startup instead: Filename must be set and not be a directory, the
+// directories of Filename and ArchiveDir must be writable, or creatable
+// unless NoCreateDir is set, the limits must not be negative, the options
+// like WithSizeString must have parsed and the settings must fit together.
+// All problems found are returned joined. It creates and removes a temporary
+// file in each directory.
+func (l *Logrotate) Validate() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.Filename == "" {
+		return ErrNoFilename
+	}
+
+	errs := append([]error(nil), l.optErrs...)
+	if info, err := os.Stat(l.Filename); err == nil && info.IsDir() {
+		errs = append(errs, fmt.Errorf("%w: %q", ErrFilenameIsDir, l.Filename))
+	}
+
+	dirs := []string{filepath.Dir(l.Filename)}
+	if l.ArchiveDir != "" {
+		dirs = append(dirs, l.backupDir())
+	}
+	for _, dir := range dirs {
+		errs = append(errs, l.checkWritable(dir))
+	}
+
+	for _, limit := range []struct {
+		name string
+		v    int64
+	}{
+		{"max backups", int64(l.MaxBackups)},
+		{"max age", int64(l.MaxAge)},
+		{"keep minimum", int64(l.KeepMinimum)},
+		{"max lines", int64(l.MaxLines)},
+		{"buffer size", int64(l.BufferSize)},
+		{"rotate retries", int64(l.RotateRetries)},
+	} {
+		if limit.v < 0 {
+			errs = append(errs, fmt.Errorf("logrotate: negative %s %d", limit.name, limit.v))
+		}
+	}
+
+	if l.MaxTotalSize > 0 && !l.DisableSizeRotation && l.MaxTotalSize < l.maxSize() {
+		errs = append(errs, fmt.Errorf("logrotate: max total size %d is smaller than max size %d", l.MaxTotalSize, l.maxSize()))
+	}
+	if l.SlidingWindow && l.DisableSizeRotation {
+		errs = append(errs, errors.New("logrotate: sliding window without size rotation"))
+	}
+
+	errs = append(errs, l.checkRotate())
+	if l.Compress && l.Compressor == nil && len(l.CompressCommand) > 0 {
+		if _, err := exec.LookPath(l.CompressCommand[0]); err != nil {
+			errs = append(errs, fmt.Errorf("logrotate: compress command: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkWritable reports whether a file can be created in dir, or in its
+// nearest existing parent if dir can be created.
+func (l *Logrotate) checkWritable(dir string) error {
+	d := dir
+	for !exists(d) {
+		if l.NoCreateDir {
+			return fmt.Errorf("logrotate: directory %q does not exist", dir)
+		}
+		if filepath.Dir(d) == d {
+			break
+		}
+		d = filepath.Dir(d)
+	}
+
+	f, err := os.CreateTemp(d, ".logrotate-*"+tempSuffix)
+	if err != nil {
+		return fmt.Errorf("logrotate: directory %q is not writable: %w", dir, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return nil
+}
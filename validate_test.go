@@ -0,0 +1,54 @@
+package logrotate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	if err := newLogrotate("").Validate(); !errors.Is(err, ErrNoFilename) {
+		t.Fatalf("no filename: Validate = %v, want ErrNoFilename", err)
+	}
+	if err := newLogrotate(dir).Validate(); !errors.Is(err, ErrFilenameIsDir) {
+		t.Fatalf("directory: Validate = %v, want ErrFilenameIsDir", err)
+	}
+
+	// a missing directory only needs to be creatable, it is not created.
+	if err := newLogrotate(filepath.Join(dir, "new", "sub", "app.log")).Validate(); err != nil {
+		t.Fatalf("missing directory: Validate = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		l    *Logrotate
+		want []string
+	}{
+		{"no directory", &Logrotate{Filename: filepath.Join(dir, "x", "app.log"), NoCreateDir: true}, []string{"does not exist"}},
+		{"negative", newLogrotate(filepath.Join(dir, "app.log"), WithMaxBackups(-1), WithKeepMinimum(-2)), []string{"negative max backups -1", "negative keep minimum -2"}},
+		{"bad size", newLogrotate(filepath.Join(dir, "app.log"), WithSizeString("ten")), []string{"ten"}},
+		{"total size", &Logrotate{Filename: filepath.Join(dir, "app.log"), MaxSize: 100, MaxTotalSize: 10}, []string{"max total size 10 is smaller than max size 100"}},
+		{"window", &Logrotate{Filename: filepath.Join(dir, "app.log"), SlidingWindow: true, DisableSizeRotation: true}, []string{"sliding window without size rotation"}},
+		{"command", &Logrotate{Filename: filepath.Join(dir, "app.log"), Compress: true, CompressCommand: []string{"logrotate-no-such-command"}}, []string{"no extension", "compress command"}},
+	}
+	for _, tt := range tests {
+		err := tt.l.Validate()
+		if err == nil {
+			t.Errorf("%s: Validate succeeded", tt.name)
+			continue
+		}
+		for _, s := range tt.want {
+			if !strings.Contains(err.Error(), s) {
+				t.Errorf("%s: Validate = %v, want %q", tt.name, err, s)
+			}
+		}
+	}
+
+	// the checks leave no files behind.
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Fatalf("left %v, %v", entries, err)
+	}
+}
@@ -0,0 +1,73 @@
+package logrotate
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// slideWindow replaces the current file by its newest half with
+// SlidingWindow.
+func (l *Logrotate) slideWindow() error {
+	if l.size <= l.maxSize()/2 {
+		// nothing to drop, a large write goes on top.
+		return nil
+	}
+
+	tail, err := l.readTail(int(l.maxSize() / 2))
+	if err != nil {
+		return err
+	}
+
+	// start at a record, unless a single one fills the window.
+	if i := bytes.Index(tail, l.delimiter()); i >= 0 && i+len(l.delimiter()) < len(tail) {
+		tail = tail[i+len(l.delimiter()):]
+	}
+
+	header := l.Header
+	if l.HeaderFunc != nil {
+		header = l.HeaderFunc()
+	}
+
+	tmp := l.Filename + tempSuffix
+	err = l.writeFile(tmp, header, tail)
+	if err != nil {
+		return err
+	}
+
+	err = l.closeFile()
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	err = l.filesystem().Rename(tmp, l.Filename)
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("logrotate: rename %q: %w", tmp, err)
+	}
+
+	return l.createFile()
+}
+
+// writeFile writes the parts to the new file name.
+func (l *Logrotate) writeFile(name string, parts ...[]byte) error {
+	f, err := l.filesystem().OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, l.fileMode())
+	if err != nil {
+		return fmt.Errorf("logrotate: open %q: %w", name, err)
+	}
+
+	for _, p := range parts {
+		if _, err := f.Write(p); err != nil {
+			f.Close()
+			os.Remove(name)
+			return fmt.Errorf("logrotate: write %q: %w", name, err)
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(name)
+		return fmt.Errorf("logrotate: close %q: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package logrotate
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestSlidingWindow(t *testing.T) {
+	filename := testFilename(t)
+	l := newLogrotate(filename, WithMaxSizeBytes(40), WithClock(newTestClock()))
+	l.SlidingWindow = true
+	l.Header = []byte("# head\n")
+	for i := 0; i < 10; i++ {
+		write(t, l, fmt.Sprintf("line %02d\n", i))
+	}
+	got := readFile(t, filename)
+	if size := l.Size(); size != int64(len(got)) {
+		t.Fatalf("Size() = %d, file has %d bytes", size, len(got))
+	}
+	closeLog(t, l)
+
+	if files := backupFiles(t, filepath.Dir(filename)); len(files) != 0 {
+		t.Fatalf("backups = %q, want none", files)
+	}
+	if len(got) > 40 {
+		t.Fatalf("file = %q, longer than MaxSize", got)
+	}
+
+	// the header and the newest whole records are kept.
+	if want := "# head\nline 06\nline 07\nline 08\nline 09\n"; got != want {
+		t.Fatalf("file = %q, want %q", got, want)
+	}
+}